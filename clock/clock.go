@@ -0,0 +1,20 @@
+// Package clock provides an abstraction of the current time so time dependent code can be tested
+package clock
+
+import "time"
+
+type Clock interface {
+	Now() time.Time
+}
+
+type clock struct {
+}
+
+// New creates a clock that returns the current system time
+func New() Clock {
+	return &clock{}
+}
+
+func (c *clock) Now() time.Time {
+	return time.Now()
+}
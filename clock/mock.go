@@ -0,0 +1,60 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// MockTime is the default time of a mock clock
+var MockTime = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Mock is a clock that only changes when it is set or advanced
+type Mock interface {
+	Clock
+	// Set changes the current time of the clock
+	Set(t time.Time)
+	// Advance moves the current time of the clock forward by the duration
+	Advance(d time.Duration)
+}
+
+type mockClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+type MockOption func(*mockClock)
+
+func WithTime(t time.Time) MockOption {
+	return func(c *mockClock) {
+		c.now = t
+	}
+}
+
+// NewMock creates a new mock clock starting at MockTime and then applies the options
+func NewMock(options ...MockOption) Mock {
+	c := &mockClock{
+		now: MockTime,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+func (c *mockClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *mockClock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = t
+}
+
+func (c *mockClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
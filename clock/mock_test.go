@@ -0,0 +1,33 @@
+package clock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockDefaultsToMockTime(t *testing.T) {
+	c := clock.NewMock()
+	require.Equal(t, clock.MockTime, c.Now())
+}
+
+func TestMockWithTime(t *testing.T) {
+	expected := time.Date(2023, time.May, 4, 3, 2, 1, 0, time.UTC)
+	c := clock.NewMock(clock.WithTime(expected))
+	require.Equal(t, expected, c.Now())
+}
+
+func TestMockAdvance(t *testing.T) {
+	c := clock.NewMock()
+	c.Advance(time.Hour)
+	require.Equal(t, clock.MockTime.Add(time.Hour), c.Now())
+}
+
+func TestMockSet(t *testing.T) {
+	expected := time.Date(2023, time.May, 4, 3, 2, 1, 0, time.UTC)
+	c := clock.NewMock()
+	c.Set(expected)
+	require.Equal(t, expected, c.Now())
+}
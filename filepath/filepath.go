@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/patrickhuber/go-collections/generic/stack"
+	"golang.org/x/text/unicode/norm"
 )
 
 type PathType string
@@ -30,7 +31,7 @@ type NullableString struct {
 type PathSeparator rune
 type PathListSeparator rune
 
-// Comparison operation determines how paths are compared. IgnoreCase or CaseSensitive
+// Comparison operation determines how paths are compared. CaseSensitive or a combination of IgnoreCase and IgnoreNormalization
 type Comparison int
 
 const (
@@ -42,6 +43,7 @@ const (
 	DefaultPathSeparator     PathSeparator     = os.PathSeparator
 	IgnoreCase               Comparison        = 1
 	CaseSensitive            Comparison        = 0
+	IgnoreNormalization      Comparison        = 2 // treats the NFC and NFD forms of a name as equal
 
 	CurrentDirectory = "."
 	ParentDirectory  = ".."
@@ -359,13 +361,39 @@ func (fp FilePath) Equal(other FilePath, cmp Comparison) bool {
 	return true
 }
 
+// Equal compares two strings using the comparison
 func (cmp Comparison) Equal(s, t string) bool {
-	if cmp == IgnoreCase {
+	if cmp.IgnoresNormalization() {
+		s = norm.NFC.String(s)
+		t = norm.NFC.String(t)
+	}
+	if cmp.IgnoresCase() {
 		return strings.EqualFold(s, t)
 	}
 	return s == t
 }
 
+// IgnoresCase returns true if the comparison treats upper and lower case as equal
+func (cmp Comparison) IgnoresCase() bool {
+	return cmp&IgnoreCase != 0
+}
+
+// IgnoresNormalization returns true if the comparison treats canonically equivalent unicode strings as equal
+func (cmp Comparison) IgnoresNormalization() bool {
+	return cmp&IgnoreNormalization != 0
+}
+
+// Normalize returns a form of the string that is the same for all strings the comparison considers equal
+func (cmp Comparison) Normalize(s string) string {
+	if cmp.IgnoresNormalization() {
+		s = norm.NFC.String(s)
+	}
+	if cmp.IgnoresCase() {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
 // Equal compares two volumes using case sensetive comparison
 func (v Volume) Equal(other Volume, cmp Comparison) bool {
 	if !v.Drive.Equal(other.Drive, cmp) {
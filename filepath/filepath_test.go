@@ -175,3 +175,40 @@ func TestVolumeName(t *testing.T) {
 		require.Equal(t, test.expected, actual)
 	}
 }
+
+func TestComparisonEqual(t *testing.T) {
+	// e acute as a single code point (NFC) and as e followed by a combining acute accent (NFD)
+	nfc := "Jos\u00e9"
+	nfd := "Jose\u0301"
+
+	type test struct {
+		cmp      filepath.Comparison
+		s        string
+		t        string
+		expected bool
+	}
+	tests := []test{
+		{filepath.CaseSensitive, "a", "a", true},
+		{filepath.CaseSensitive, "a", "A", false},
+		{filepath.CaseSensitive, nfc, nfd, false},
+		{filepath.IgnoreCase, "a", "A", true},
+		{filepath.IgnoreCase, nfc, nfd, false},
+		{filepath.IgnoreNormalization, nfc, nfd, true},
+		{filepath.IgnoreNormalization, "a", "A", false},
+		{filepath.IgnoreCase | filepath.IgnoreNormalization, "JOS\u00c9", nfc, true},
+	}
+	for i, test := range tests {
+		require.Equal(t, test.expected, test.cmp.Equal(test.s, test.t), "test [%d] failed", i)
+		normalized := test.cmp.Normalize(test.s) == test.cmp.Normalize(test.t)
+		require.Equal(t, test.expected, normalized, "test [%d] failed", i)
+	}
+}
+
+func TestFilePathEqualIgnoresNormalization(t *testing.T) {
+	nfc := filepath.FilePath{Absolute: true, Segments: []string{"Users", "Jos\u00e9"}}
+	nfd := filepath.FilePath{Absolute: true, Segments: []string{"users", "jose\u0301"}}
+
+	require.True(t, nfc.Equal(nfd, filepath.IgnoreCase|filepath.IgnoreNormalization))
+	require.False(t, nfc.Equal(nfd, filepath.IgnoreCase))
+	require.False(t, nfc.Equal(nfd, filepath.CaseSensitive))
+}
@@ -2,6 +2,7 @@ package filepath
 
 import (
 	"regexp"
+	"strings"
 
 	"github.com/patrickhuber/go-xplat/os"
 )
@@ -15,19 +16,27 @@ type Processor struct {
 
 type ProcessorOption func(p *Processor)
 
+// WithComparison overrides the comparison of the platform. Use it to emulate file systems that differ from
+// the platform default, for example IgnoreCase|IgnoreNormalization for the default macOS volumes.
+func WithComparison(comparison Comparison) ProcessorOption {
+	return func(p *Processor) {
+		p.Comparison = comparison
+	}
+}
+
 // NewProcessor creates a processor with the default platform and then applies the options
-func NewProcessor() *Processor {
-	return NewProcessorWithOS(os.New())
+func NewProcessor(options ...ProcessorOption) *Processor {
+	return NewProcessorWithOS(os.New(), options...)
 }
 
-// NewProcessorWithOS creates a processor from the OS
-func NewProcessorWithOS(o os.OS) *Processor {
+// NewProcessorWithOS creates a processor from the OS and then applies the options. Unix platforms, macOS
+// included, compare names case sensitively unless the comparison is overridden.
+func NewProcessorWithOS(o os.OS, options ...ProcessorOption) *Processor {
 	p := &Processor{
 		Parser: NewParserWithPlatform(o.Platform()),
 		OS:     o,
 	}
 
-	// run defaults after all options have passed
 	if o.Platform().IsUnix() {
 		p.Separator = ForwardSlash
 		p.Comparison = CaseSensitive
@@ -35,6 +44,9 @@ func NewProcessorWithOS(o os.OS) *Processor {
 		p.Separator = BackwardSlash
 		p.Comparison = IgnoreCase
 	}
+	for _, option := range options {
+		option(p)
+	}
 	return p
 }
 
@@ -172,3 +184,58 @@ func (p *Processor) Base(path string) string {
 func (p *Processor) String(fp FilePath) string {
 	return fp.String(p.Separator)
 }
+
+// ToSlash returns the relative path with forward slashes, the form used by archives. Absolute paths are
+// returned unchanged.
+func (p *Processor) ToSlash(path string) string {
+	fp, err := p.Parser.Parse(path)
+	if err != nil || fp.IsAbs() {
+		return path
+	}
+	return strings.Join(fp.Segments, "/")
+}
+
+// FromSlash returns the relative slash separated path with the separator of the processor. Absolute paths
+// are returned unchanged.
+func (p *Processor) FromSlash(path string) string {
+	if strings.HasPrefix(path, "/") {
+		return path
+	}
+	return p.Join(strings.Split(path, "/")...)
+}
+
+// SplitSlash splits the relative slash separated path into segments and resolves "." and "..". The path may
+// go up at most depth segments which leaves leading ".." segments in the result. It returns false if the path
+// is absolute, goes up more than depth segments or has a segment the processor would not read as one name,
+// for example "c:" or `..\a` on windows. Use it to join untrusted names from an archive to a root.
+func (p *Processor) SplitSlash(path string, depth int) ([]string, bool) {
+	if strings.HasPrefix(path, "/") || p.VolumeName(path) != "" {
+		return nil, false
+	}
+	var segments []string
+	up := 0
+	for _, segment := range strings.Split(path, "/") {
+		switch segment {
+		case "", CurrentDirectory:
+			continue
+		case ParentDirectory:
+			if len(segments) > up {
+				segments = segments[:len(segments)-1]
+				continue
+			}
+			if up == depth {
+				return nil, false
+			}
+			up++
+			segments = append(segments, segment)
+			continue
+		}
+		fp, err := p.Parser.Parse(segment)
+		if err != nil || fp.IsAbs() || p.VolumeName(segment) != "" ||
+			len(fp.Segments) != 1 || fp.Segments[0] != segment {
+			return nil, false
+		}
+		segments = append(segments, segment)
+	}
+	return segments, true
+}
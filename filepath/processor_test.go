@@ -160,8 +160,21 @@ func TestRel(t *testing.T) {
 		{`\\host\share\folder`, `\\other\test\share`, `err`},
 	}
 
-	run := func(tests []test, name string, o os.OS) {
-		processor := filepath.NewProcessorWithOS(o)
+	darwinreltests := []test{
+		{"/Users/Fake", "/users/fake/Documents", "Documents"},
+		{"/Users/Fake/Documents", "/users/fake", ".."},
+		{"/Users/Fake", "/Users/Other", "../Other"},
+		{"/Users/Jos\u00e9", "/Users/Jose\u0301/Documents", "Documents"},
+		{"/Users/Jose\u0301/Documents", "/users/JOS\u00c9", ".."},
+	}
+
+	linuxcasetests := []test{
+		{"/home/Fake", "/home/fake/documents", "../fake/documents"},
+		{"/home/Jos\u00e9", "/home/Jose\u0301", "../Jose\u0301"},
+	}
+
+	run := func(tests []test, name string, o os.OS, options ...filepath.ProcessorOption) {
+		processor := filepath.NewProcessorWithOS(o, options...)
 		for i, test := range tests {
 
 			actual, err := processor.Rel(test.source, test.target)
@@ -175,6 +188,34 @@ func TestRel(t *testing.T) {
 	}
 	run(reltests, "reltests", os.NewMock(os.WithPlatform(platform.Linux)))
 	run(winreltests, "winreltests", os.NewMock(os.WithPlatform(platform.Windows)))
+	run(darwinreltests, "darwinreltests", os.NewMock(os.WithPlatform(platform.Darwin)),
+		filepath.WithComparison(filepath.IgnoreCase|filepath.IgnoreNormalization))
+	run(linuxcasetests, "linuxcasetests", os.NewMock(os.WithPlatform(platform.Linux)))
+}
+
+func TestNewProcessorWithOSDefaults(t *testing.T) {
+	type test struct {
+		platform   platform.Platform
+		separator  filepath.PathSeparator
+		comparison filepath.Comparison
+	}
+	tests := []test{
+		{platform.Linux, filepath.ForwardSlash, filepath.CaseSensitive},
+		{platform.Darwin, filepath.ForwardSlash, filepath.CaseSensitive},
+		{platform.Windows, filepath.BackwardSlash, filepath.IgnoreCase},
+	}
+	for _, test := range tests {
+		processor := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(test.platform)))
+		require.Equal(t, test.separator, processor.Separator, "platform %s", test.platform)
+		require.Equal(t, test.comparison, processor.Comparison, "platform %s", test.platform)
+	}
+}
+
+func TestNewProcessorWithComparison(t *testing.T) {
+	processor := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(platform.Darwin)),
+		filepath.WithComparison(filepath.IgnoreCase|filepath.IgnoreNormalization))
+	require.Equal(t, filepath.ForwardSlash, processor.Separator)
+	require.Equal(t, filepath.IgnoreCase|filepath.IgnoreNormalization, processor.Comparison)
 }
 
 func TestClean(t *testing.T) {
@@ -442,3 +483,41 @@ func TestAbs(t *testing.T) {
 	run("abs_darwin", absDirs, relPaths, os.NewMock(os.WithPlatform(platform.Darwin)))
 	run("abs_windows", absDirs, relPaths, os.NewMock(os.WithPlatform(platform.Windows)))
 }
+
+func TestSlash(t *testing.T) {
+	linux := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(platform.Linux)))
+	windows := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(platform.Windows)))
+
+	require.Equal(t, "a/b", windows.ToSlash(`a\b`))
+	require.Equal(t, `c:\a`, windows.ToSlash(`c:\a`))
+	require.Equal(t, `a\b`, windows.FromSlash("a/b"))
+	require.Equal(t, "/a/b", windows.FromSlash("/a/b"))
+	require.Equal(t, "a/b", linux.FromSlash("a/b"))
+}
+
+func TestSplitSlash(t *testing.T) {
+	type test struct {
+		path     string
+		depth    int
+		expected []string
+		ok       bool
+	}
+	tests := []test{
+		{"a/b", 0, []string{"a", "b"}, true},
+		{"./a//b/", 0, []string{"a", "b"}, true},
+		{"a/../b", 0, []string{"b"}, true},
+		{"../a", 1, []string{"..", "a"}, true},
+		{"../a", 0, nil, false},
+		{"a/../../b", 0, nil, false},
+		{"/a", 0, nil, false},
+		{`c:\a`, 0, nil, false},
+		{`a\..\..\b`, 0, nil, false},
+		{"c:", 0, nil, false},
+	}
+	windows := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(platform.Windows)))
+	for _, test := range tests {
+		segments, ok := windows.SplitSlash(test.path, test.depth)
+		require.Equal(t, test.ok, ok, test.path)
+		require.Equal(t, test.expected, segments, test.path)
+	}
+}
@@ -0,0 +1,35 @@
+// Package archive extracts tar, tar.gz and zip archives into a file system and creates archives from a file system.
+//
+// Extraction never writes outside of the root. Entries with absolute names, names that leave the root
+// through "..", symbolic links that point outside of the root and entries that would be written through a
+// symbolic link fail with fs.ErrPathEscapes.
+package archive
+
+import (
+	"github.com/patrickhuber/go-xplat/filepath"
+)
+
+// options holds the options of the extract and create functions
+type options struct {
+	processor *filepath.Processor
+}
+
+type Option = func(*options)
+
+// WithProcessor sets the processor used to join entry names to the root
+func WithProcessor(processor *filepath.Processor) Option {
+	return func(o *options) {
+		o.processor = processor
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, option := range opts {
+		option(o)
+	}
+	if o.processor == nil {
+		o.processor = filepath.NewProcessor()
+	}
+	return o
+}
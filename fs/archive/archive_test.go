@@ -0,0 +1,221 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/clock"
+	"github.com/patrickhuber/go-xplat/filepath"
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/fs/archive"
+	"github.com/patrickhuber/go-xplat/fs/txtar"
+	"github.com/patrickhuber/go-xplat/os"
+	"github.com/patrickhuber/go-xplat/platform"
+	"github.com/stretchr/testify/require"
+)
+
+const release = `-- app/bin/run.sh mode=0755 mtime=2020-01-02T03:04:05Z --
+#!/bin/sh
+-- app/empty/ mode=0700 mtime=2020-01-02T03:04:05Z --
+-- app/lib/libapp.so.1 mtime=2020-01-02T03:04:05Z --
+library
+-- app/lib/libapp.so symlink=libapp.so.1 --
+-- app/readme.md mtime=2020-01-02T03:04:05Z --
+# readme
+`
+
+func setup(t *testing.T, p platform.Platform) (fs.FS, *filepath.Processor) {
+	processor := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(p)))
+	fsys := fs.NewMemory(fs.WithProcessor(processor), fs.WithClock(clock.NewMock()))
+	return fsys, processor
+}
+
+// requireSame requires the entries under root in both file systems to be the same
+func requireSame(t *testing.T, expected fs.FS, actual fs.FS, root string) {
+	e, err := txtar.DumpString(expected, root, txtar.WithModTime())
+	require.NoError(t, err)
+	a, err := txtar.DumpString(actual, root, txtar.WithModTime())
+	require.NoError(t, err)
+	require.Equal(t, e, a)
+}
+
+func TestTarRoundTrip(t *testing.T) {
+	source, _ := setup(t, platform.Linux)
+	require.NoError(t, txtar.LoadString(source, "/src", release))
+
+	var buf bytes.Buffer
+	require.NoError(t, archive.CreateTar(&buf, source, "/src"))
+
+	target, _ := setup(t, platform.Linux)
+	require.NoError(t, archive.ExtractTar(target, "/src", &buf))
+	requireSame(t, source, target, "/src")
+
+	link, err := fs.Readlink(target, "/src/app/lib/libapp.so")
+	require.NoError(t, err)
+	require.Equal(t, "libapp.so.1", link)
+}
+
+func TestTarGzipRoundTrip(t *testing.T) {
+	source, _ := setup(t, platform.Linux)
+	require.NoError(t, txtar.LoadString(source, "/src", release))
+
+	var buf bytes.Buffer
+	require.NoError(t, archive.CreateTarGzip(&buf, source, "/src"))
+
+	target, _ := setup(t, platform.Linux)
+	require.NoError(t, archive.ExtractTarGzip(target, "/src", &buf))
+	requireSame(t, source, target, "/src")
+}
+
+func TestZipRoundTrip(t *testing.T) {
+	source, _ := setup(t, platform.Linux)
+	require.NoError(t, txtar.LoadString(source, "/src", release))
+
+	var buf bytes.Buffer
+	require.NoError(t, archive.CreateZip(&buf, source, "/src"))
+
+	target, _ := setup(t, platform.Linux)
+	require.NoError(t, archive.ExtractZip(target, "/src", bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	requireSame(t, source, target, "/src")
+}
+
+func TestExtractWindows(t *testing.T) {
+	source, _ := setup(t, platform.Linux)
+	require.NoError(t, txtar.LoadString(source, "/src", release))
+	var buf bytes.Buffer
+	require.NoError(t, archive.CreateTar(&buf, source, "/src"))
+
+	target, processor := setup(t, platform.Windows)
+	require.NoError(t, archive.ExtractTar(target, `c:\dst`, &buf, archive.WithProcessor(processor)))
+
+	data, err := target.ReadFile(`c:\dst\app\bin\run.sh`)
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh\n", string(data))
+
+	expected, err := txtar.DumpString(source, "/src", txtar.WithModTime())
+	require.NoError(t, err)
+	actual, err := txtar.DumpString(target, `c:\dst`, txtar.WithProcessor(processor), txtar.WithModTime())
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func TestExtractTarHardLink(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	writeTar(t, tw, &tar.Header{Typeflag: tar.TypeReg, Name: "./bin/app", Mode: 0755}, "binary")
+	writeTar(t, tw, &tar.Header{Typeflag: tar.TypeLink, Name: "./bin/alias", Linkname: "./bin/app"}, "")
+	require.NoError(t, tw.Close())
+
+	target, _ := setup(t, platform.Linux)
+	require.NoError(t, archive.ExtractTar(target, "/dst", &buf))
+
+	data, err := target.ReadFile("/dst/bin/alias")
+	require.NoError(t, err)
+	require.Equal(t, "binary", string(data))
+}
+
+func TestExtractTarRejectsPathTraversal(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []*tar.Header
+	}{
+		{"parent", []*tar.Header{
+			{Typeflag: tar.TypeReg, Name: "../evil.txt"},
+		}},
+		{"nested parent", []*tar.Header{
+			{Typeflag: tar.TypeReg, Name: "app/../../evil.txt"},
+		}},
+		{"absolute", []*tar.Header{
+			{Typeflag: tar.TypeReg, Name: "/evil.txt"},
+		}},
+		{"symlink target", []*tar.Header{
+			{Typeflag: tar.TypeSymlink, Name: "app/link", Linkname: "../../evil.txt"},
+		}},
+		{"absolute symlink target", []*tar.Header{
+			{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "/evil.txt"},
+		}},
+		{"through symlink", []*tar.Header{
+			{Typeflag: tar.TypeDir, Name: "app/", Mode: 0755},
+			{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "app"},
+			{Typeflag: tar.TypeReg, Name: "link/evil.txt"},
+		}},
+		{"hard link", []*tar.Header{
+			{Typeflag: tar.TypeLink, Name: "evil.txt", Linkname: "../secret.txt"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, header := range test.headers {
+				writeTar(t, tw, header, "")
+			}
+			require.NoError(t, tw.Close())
+
+			target, _ := setup(t, platform.Linux)
+			require.NoError(t, target.MkdirAll("/gran", 0755))
+			require.NoError(t, target.WriteFile("/gran/secret.txt", []byte("secret"), 0600))
+			err := archive.ExtractTar(target, "/gran/dst", &buf)
+			require.ErrorIs(t, err, fs.ErrPathEscapes)
+
+			for _, name := range []string{"/evil.txt", "/gran/evil.txt", "/gran/dst/evil.txt"} {
+				ok, err := target.Exists(name)
+				require.NoError(t, err)
+				require.False(t, ok, name)
+			}
+		})
+	}
+}
+
+func TestExtractZipRejectsPathTraversal(t *testing.T) {
+	for _, name := range []string{"../evil.txt", `..\evil.txt`, `c:\evil.txt`} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			w, err := zw.Create(name)
+			require.NoError(t, err)
+			_, err = w.Write([]byte("evil"))
+			require.NoError(t, err)
+			require.NoError(t, zw.Close())
+
+			target, processor := setup(t, platform.Windows)
+			err = archive.ExtractZip(target, `c:\dst`, bytes.NewReader(buf.Bytes()), int64(buf.Len()),
+				archive.WithProcessor(processor))
+			require.ErrorIs(t, err, fs.ErrPathEscapes)
+
+			ok, err := target.Exists(`c:\evil.txt`)
+			require.NoError(t, err)
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestExtractReplacesExistingSymlink(t *testing.T) {
+	target, _ := setup(t, platform.Linux)
+	require.NoError(t, target.MkdirAll("/gran/dst", 0755))
+	require.NoError(t, target.WriteFile("/gran/secret.txt", []byte("secret"), 0600))
+	require.NoError(t, fs.Symlink(target, "/gran/secret.txt", "/gran/dst/file.txt"))
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	writeTar(t, tw, &tar.Header{Typeflag: tar.TypeReg, Name: "file.txt", Mode: 0644, ModTime: time.Now()}, "new")
+	require.NoError(t, tw.Close())
+	require.NoError(t, archive.ExtractTar(target, "/gran/dst", &buf))
+
+	data, err := target.ReadFile("/gran/secret.txt")
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(data))
+	data, err = target.ReadFile("/gran/dst/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "new", string(data))
+}
+
+func writeTar(t *testing.T, tw *tar.Writer, header *tar.Header, content string) {
+	header.Size = int64(len(content))
+	require.NoError(t, tw.WriteHeader(header))
+	_, err := tw.Write([]byte(content))
+	require.NoError(t, err)
+}
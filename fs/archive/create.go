@@ -0,0 +1,141 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	iofs "io/fs"
+	"sort"
+
+	"github.com/patrickhuber/go-xplat/fs"
+)
+
+// entry is a file system entry found by walk
+type entry struct {
+	// name is the slash separated name relative to the root. Directories end with a slash.
+	name string
+	path string
+	info iofs.FileInfo
+	// link is the slash separated target of a symbolic link
+	link string
+}
+
+// walk calls fn for each directory, regular file and symbolic link under dir sorted by name. Directories are
+// visited before their children. prefix is the entry name of dir.
+func (o *options) walk(fsys fs.FS, dir string, prefix string, fn func(entry) error) error {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	for _, dirEntry := range entries {
+		e := entry{name: prefix + dirEntry.Name(), path: o.processor.Join(dir, dirEntry.Name())}
+		e.info, err = fs.Lstat(fsys, e.path)
+		if err != nil {
+			return err
+		}
+		mode := e.info.Mode()
+		switch {
+		case mode&iofs.ModeSymlink != 0:
+			target, err := fs.Readlink(fsys, e.path)
+			if err != nil {
+				return err
+			}
+			e.link = o.processor.ToSlash(target)
+		case mode.IsDir():
+			e.name += "/"
+		case !mode.IsRegular():
+			// devices, pipes and sockets can't be archived
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+		if mode.IsDir() {
+			if err := o.walk(fsys, e.path, e.name, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CreateTarGzip writes the entries of fsys under root to w as a gzip compressed tar stream
+func CreateTarGzip(w io.Writer, fsys fs.FS, root string, options ...Option) error {
+	gw := gzip.NewWriter(w)
+	if err := CreateTar(gw, fsys, root, options...); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// CreateTar writes the entries of fsys under root to w as a tar stream. Entry names are relative to root.
+func CreateTar(w io.Writer, fsys fs.FS, root string, options ...Option) error {
+	o := newOptions(options)
+	tw := tar.NewWriter(w)
+	err := o.walk(fsys, root, "", func(e entry) error {
+		header, err := tar.FileInfoHeader(e.info, e.link)
+		if err != nil {
+			return err
+		}
+		header.Name = e.name
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !e.info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(tw, fsys, e.path)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// CreateZip writes the entries of fsys under root to w as a zip archive. Entry names are relative to root.
+func CreateZip(w io.Writer, fsys fs.FS, root string, options ...Option) error {
+	o := newOptions(options)
+	zw := zip.NewWriter(w)
+	err := o.walk(fsys, root, "", func(e entry) error {
+		header, err := zip.FileInfoHeader(e.info)
+		if err != nil {
+			return err
+		}
+		header.Name = e.name
+		if e.info.Mode().IsRegular() {
+			header.Method = zip.Deflate
+		}
+		writer, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if e.link != "" {
+			// zip stores the target of a symbolic link as its content
+			_, err = io.WriteString(writer, e.link)
+			return err
+		}
+		if !e.info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(writer, fsys, e.path)
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// copyFile copies the content of the named file to w
+func copyFile(w io.Writer, fsys fs.FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,289 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/fs"
+)
+
+// extractor writes the entries of an archive under root
+type extractor struct {
+	*options
+	fsys    fs.FS
+	root    string
+	pending []attributes
+}
+
+// attributes are applied after every entry is extracted. Creating a child changes the modification time of
+// its directory and a read only directory can't be written to.
+type attributes struct {
+	name    string
+	mode    iofs.FileMode
+	modTime time.Time
+}
+
+func newExtractor(fsys fs.FS, root string, opts []Option) (*extractor, error) {
+	e := &extractor{
+		options: newOptions(opts),
+		fsys:    fsys,
+		root:    root,
+	}
+	return e, fsys.MkdirAll(root, 0755)
+}
+
+// ExtractTarGzip extracts the gzip compressed tar stream r into fsys under root
+func ExtractTarGzip(fsys fs.FS, root string, r io.Reader, options ...Option) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	return ExtractTar(fsys, root, gr, options...)
+}
+
+// ExtractTar extracts the tar stream r into fsys under root. Regular files, directories, symbolic links and
+// hard links are extracted with their modes and modification times. Other entry types are skipped.
+func ExtractTar(fsys fs.FS, root string, r io.Reader, options ...Option) error {
+	e, err := newExtractor(fsys, root, options)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		info := header.FileInfo()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = e.dir(header.Name, info.Mode(), header.ModTime)
+		case tar.TypeReg:
+			err = e.file(header.Name, tr, info.Mode(), header.ModTime)
+		case tar.TypeSymlink:
+			err = e.symlink(header.Name, header.Linkname)
+		case tar.TypeLink:
+			err = e.link(header.Name, header.Linkname)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.finish()
+}
+
+// ExtractZip extracts the zip archive r of the given size into fsys under root. Regular files, directories and
+// symbolic links are extracted with their modes and modification times.
+func ExtractZip(fsys fs.FS, root string, r io.ReaderAt, size int64, options ...Option) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	e, err := newExtractor(fsys, root, options)
+	if err != nil {
+		return err
+	}
+	for _, file := range zr.File {
+		if err := e.zipFile(file); err != nil {
+			return err
+		}
+	}
+	return e.finish()
+}
+
+// zipFile extracts a single entry of a zip archive
+func (e *extractor) zipFile(file *zip.File) error {
+	mode := file.Mode()
+	switch {
+	case mode.IsDir():
+		return e.dir(file.Name, mode, file.Modified)
+	case mode&iofs.ModeSymlink != 0:
+		target, err := readAll(file)
+		if err != nil {
+			return err
+		}
+		return e.symlink(file.Name, string(target))
+	case !mode.IsRegular():
+		return nil
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return e.file(file.Name, rc, mode, file.Modified)
+}
+
+// readAll returns the content of the zip entry
+func readAll(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// dir creates the directory entry name
+func (e *extractor) dir(name string, mode iofs.FileMode, modTime time.Time) error {
+	target, err := e.prepare(name)
+	if err != nil || target == e.root {
+		return err
+	}
+	if err := e.fsys.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	e.pending = append(e.pending, attributes{name: target, mode: mode, modTime: modTime})
+	return nil
+}
+
+// file writes the content of r to the file entry name
+func (e *extractor) file(name string, r io.Reader, mode iofs.FileMode, modTime time.Time) error {
+	target, err := e.prepare(name)
+	if err != nil {
+		return err
+	}
+	if target == e.root {
+		return &iofs.PathError{Op: "extract", Path: name, Err: iofs.ErrInvalid}
+	}
+	f, err := e.fsys.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	e.pending = append(e.pending, attributes{name: target, mode: mode, modTime: modTime})
+	return nil
+}
+
+// symlink creates the symbolic link entry name. The target must stay under the root.
+func (e *extractor) symlink(name string, linkname string) error {
+	segments, ok := e.processor.SplitSlash(name, 0)
+	if !ok {
+		return escapes(name)
+	}
+	if len(segments) == 0 {
+		return &iofs.PathError{Op: "extract", Path: name, Err: iofs.ErrInvalid}
+	}
+	targetSegments, ok := e.processor.SplitSlash(linkname, len(segments)-1)
+	if !ok {
+		return &os.LinkError{Op: "extract", Old: linkname, New: name, Err: fs.ErrPathEscapes}
+	}
+	newname, err := e.prepare(name)
+	if err != nil {
+		return err
+	}
+	oldname := "."
+	if len(targetSegments) > 0 {
+		oldname = e.processor.Join(targetSegments...)
+	}
+	return fs.Symlink(e.fsys, oldname, newname)
+}
+
+// link creates the hard link entry name to the previously extracted entry linkname
+func (e *extractor) link(name string, linkname string) error {
+	segments, ok := e.processor.SplitSlash(linkname, 0)
+	if !ok || len(segments) == 0 {
+		return &os.LinkError{Op: "extract", Old: linkname, New: name, Err: fs.ErrPathEscapes}
+	}
+	if err := e.checkParents(segments); err != nil {
+		return err
+	}
+	newname, err := e.prepare(name)
+	if err != nil {
+		return err
+	}
+	return fs.Link(e.fsys, e.join(segments), newname)
+}
+
+// prepare returns the path of the entry name under the root. It creates the parent directories and removes
+// an existing entry that is not a directory so the entry replaces it instead of writing through it.
+func (e *extractor) prepare(name string) (string, error) {
+	segments, ok := e.processor.SplitSlash(name, 0)
+	if !ok {
+		return "", escapes(name)
+	}
+	if len(segments) == 0 {
+		return e.root, nil
+	}
+	if err := e.checkParents(segments); err != nil {
+		return "", err
+	}
+	target := e.join(segments)
+	if err := e.fsys.MkdirAll(e.processor.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	info, err := fs.Lstat(e.fsys, target)
+	switch {
+	case errors.Is(err, iofs.ErrNotExist):
+		return target, nil
+	case err != nil:
+		return "", err
+	case info.IsDir():
+		return target, nil
+	}
+	return target, e.fsys.Remove(target)
+}
+
+// checkParents fails if a parent of the entry is a symbolic link. Following it could leave the root.
+func (e *extractor) checkParents(segments []string) error {
+	for i := 1; i < len(segments); i++ {
+		info, err := fs.Lstat(e.fsys, e.join(segments[:i]))
+		if errors.Is(err, iofs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&iofs.ModeSymlink != 0 {
+			return escapes(e.join(segments))
+		}
+	}
+	return nil
+}
+
+// join joins the segments to the root
+func (e *extractor) join(segments []string) string {
+	return e.processor.Join(append([]string{e.root}, segments...)...)
+}
+
+// finish applies the modes and modification times. Children are visited before their parents. A file system
+// that can't change metadata keeps the permissions the entries were created with.
+func (e *extractor) finish() error {
+	if _, ok := e.fsys.(fs.ChangeFS); !ok {
+		return nil
+	}
+	for i := len(e.pending) - 1; i >= 0; i-- {
+		attr := e.pending[i]
+		mode := attr.mode & (iofs.ModePerm | iofs.ModeSetuid | iofs.ModeSetgid | iofs.ModeSticky)
+		if err := fs.Chmod(e.fsys, attr.name, mode); err != nil {
+			return err
+		}
+		if attr.modTime.IsZero() {
+			continue
+		}
+		if err := fs.Chtimes(e.fsys, attr.name, attr.modTime, attr.modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func escapes(name string) error {
+	return &iofs.PathError{Op: "extract", Path: name, Err: fs.ErrPathEscapes}
+}
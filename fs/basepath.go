@@ -0,0 +1,429 @@
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+)
+
+// ErrPathEscapes is returned when a name, or a symbolic link it contains, refers to a location outside the root of a base path file system
+// or of an archive being extracted
+var ErrPathEscapes = errors.New("path escapes from parent")
+
+// basePath confines a file system to a root directory. Relative names start at the root and symbolic
+// links are resolved by the base path so a link can't point outside of the root.
+type basePath struct {
+	fsys      FS
+	root      string
+	processor *filepath.Processor
+}
+
+type BasePathOption = func(*basePath)
+
+// WithBasePathProcessor sets the processor used to join names to the root
+func WithBasePathProcessor(processor *filepath.Processor) BasePathOption {
+	return func(b *basePath) {
+		b.processor = processor
+	}
+}
+
+// NewBasePath creates a file system that resolves every relative name from root. Absolute names outside of
+// root and names that leave root through ".." or a symbolic link fail with ErrPathEscapes.
+func NewBasePath(fsys FS, root string, options ...BasePathOption) FS {
+	b := &basePath{
+		fsys: fsys,
+		root: root,
+	}
+	for _, option := range options {
+		option(b)
+	}
+	if b.processor == nil {
+		b.processor = filepath.NewProcessor()
+	}
+	if abs, err := b.processor.Abs(root); err == nil {
+		b.root = abs
+	} else {
+		b.root = b.processor.Clean(root)
+	}
+	return b
+}
+
+// resolve returns the name in the wrapped file system. Symbolic links in the parent directories are always
+// resolved, a symbolic link in the last segment only if follow is true.
+func (b *basePath) resolve(op string, name string, follow bool) (string, error) {
+	segments, err := b.segments(name)
+	if err != nil {
+		return "", &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+	segments, err = b.walk(segments, follow)
+	if err != nil {
+		return "", &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+	return b.full(segments), nil
+}
+
+// segments returns the segments of the name relative to the root. Absolute names must be under the root.
+func (b *basePath) segments(name string) ([]string, error) {
+	fp, err := b.processor.Parser.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	if !fp.IsAbs() && fp.Volume == (filepath.Volume{}) {
+		return fp.Segments, nil
+	}
+	rel, err := b.processor.Rel(b.root, name)
+	if err != nil {
+		return nil, ErrPathEscapes
+	}
+	fp, err = b.processor.Parser.Parse(rel)
+	if err != nil {
+		return nil, err
+	}
+	if len(fp.Segments) > 0 && fp.Segments[0] == ".." {
+		return nil, ErrPathEscapes
+	}
+	return fp.Segments, nil
+}
+
+// full joins the segments to the root
+func (b *basePath) full(segments []string) string {
+	return b.processor.Join(append([]string{b.root}, segments...)...)
+}
+
+// walk resolves the segments one at a time and returns the segments of the result relative to the root
+func (b *basePath) walk(segments []string, follow bool) ([]string, error) {
+	var resolved []string
+	pending := append([]string(nil), segments...)
+	hops := 0
+	missing := false
+
+	for len(pending) > 0 {
+		segment := pending[0]
+		pending = pending[1:]
+
+		switch segment {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return nil, ErrPathEscapes
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+
+		candidate := append(resolved[:len(resolved):len(resolved)], segment)
+		if missing || (len(pending) == 0 && !follow) {
+			resolved = candidate
+			continue
+		}
+		full := b.full(candidate)
+		info, err := Lstat(b.fsys, full)
+		if err != nil {
+			// the rest of the name is left for the wrapped file system to fail on
+			missing = true
+			resolved = candidate
+			continue
+		}
+		if info.Mode()&iofs.ModeSymlink == 0 {
+			resolved = candidate
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return nil, syscall.ELOOP
+		}
+		target, err := Readlink(b.fsys, full)
+		if err != nil {
+			return nil, unwrap(err)
+		}
+		fp, err := b.processor.Parser.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		if fp.IsAbs() || fp.Volume != (filepath.Volume{}) {
+			resolved = nil
+		}
+		targetSegments, err := b.segments(target)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(append([]string(nil), targetSegments...), pending...)
+	}
+	return resolved, nil
+}
+
+// fixErr replaces the names of the wrapped file system with the names relative to the root
+func (b *basePath) fixErr(err error, name string) error {
+	var pathError *iofs.PathError
+	if errors.As(err, &pathError) {
+		pathError.Path = name
+	}
+	return err
+}
+
+// fixLinkErr replaces the names of the wrapped file system with the names relative to the root
+func (b *basePath) fixLinkErr(err error, oldname string, newname string) error {
+	var linkError *os.LinkError
+	if errors.As(err, &linkError) {
+		linkError.Old = oldname
+		linkError.New = newname
+		return err
+	}
+	return b.fixErr(err, newname)
+}
+
+// Open implements FS
+func (b *basePath) Open(name string) (iofs.File, error) {
+	full, err := b.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	f, err := b.fsys.Open(full)
+	if err != nil {
+		return nil, b.fixErr(err, name)
+	}
+	if file, ok := f.(File); ok {
+		return &basePathFile{File: file, name: name}, nil
+	}
+	return f, nil
+}
+
+// OpenFile implements OpenFileFS. A symbolic link in the last segment is not followed with O_CREATE|O_EXCL.
+func (b *basePath) OpenFile(name string, flag int, perm iofs.FileMode) (File, error) {
+	exclusive := flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0
+	full, err := b.resolve("open", name, !exclusive)
+	if err != nil {
+		return nil, err
+	}
+	f, err := b.fsys.OpenFile(full, flag, perm)
+	if err != nil {
+		return nil, b.fixErr(err, name)
+	}
+	return &basePathFile{File: f, name: name}, nil
+}
+
+// Create implements CreateFS
+func (b *basePath) Create(name string) (File, error) {
+	return b.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// WriteFile implements WriteFileFS
+func (b *basePath) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	full, err := b.resolve("open", name, true)
+	if err != nil {
+		return err
+	}
+	return b.fixErr(b.fsys.WriteFile(full, data, perm), name)
+}
+
+// ReadFile implements iofs.ReadFileFS
+func (b *basePath) ReadFile(name string) ([]byte, error) {
+	full, err := b.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	data, err := b.fsys.ReadFile(full)
+	return data, b.fixErr(err, name)
+}
+
+// ReadDir implements iofs.ReadDirFS
+func (b *basePath) ReadDir(name string) ([]iofs.DirEntry, error) {
+	full, err := b.resolve("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := b.fsys.ReadDir(full)
+	return entries, b.fixErr(err, name)
+}
+
+// Stat implements iofs.StatFS
+func (b *basePath) Stat(name string) (iofs.FileInfo, error) {
+	full, err := b.resolve("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info, err := b.fsys.Stat(full)
+	if err != nil {
+		return nil, b.fixErr(err, name)
+	}
+	return &namedInfo{FileInfo: info, name: b.processor.Base(name)}, nil
+}
+
+// Lstat implements SymlinkFS
+func (b *basePath) Lstat(name string) (iofs.FileInfo, error) {
+	full, err := b.resolve("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	info, err := Lstat(b.fsys, full)
+	return info, b.fixErr(err, name)
+}
+
+// Readlink implements SymlinkFS. The target is returned as stored.
+func (b *basePath) Readlink(name string) (string, error) {
+	full, err := b.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	target, err := Readlink(b.fsys, full)
+	return target, b.fixErr(err, name)
+}
+
+// Exists implements ExistsFS
+func (b *basePath) Exists(name string) (bool, error) {
+	full, err := b.resolve("exists", name, true)
+	if err != nil {
+		return false, err
+	}
+	ok, err := b.fsys.Exists(full)
+	return ok, b.fixErr(err, name)
+}
+
+// Glob implements iofs.GlobFS. Patterns are relative to the root.
+func (b *basePath) Glob(pattern string) ([]string, error) {
+	return glob(b.processor, pattern, b.ReadDir, func(name string) bool {
+		_, err := b.Lstat(name)
+		return err == nil
+	})
+}
+
+// Sub implements iofs.SubFS
+func (b *basePath) Sub(dir string) (iofs.FS, error) {
+	return newSubFS(b, b.processor, dir), nil
+}
+
+// Mkdir implements MakeDirFS
+func (b *basePath) Mkdir(name string, perm iofs.FileMode) error {
+	full, err := b.resolve("mkdir", name, false)
+	if err != nil {
+		return err
+	}
+	return b.fixErr(b.fsys.Mkdir(full, perm), name)
+}
+
+// MkdirAll implements MakeDirFS
+func (b *basePath) MkdirAll(name string, perm iofs.FileMode) error {
+	full, err := b.resolve("mkdir", name, true)
+	if err != nil {
+		return err
+	}
+	return b.fixErr(b.fsys.MkdirAll(full, perm), name)
+}
+
+// Remove implements RemoveFS
+func (b *basePath) Remove(name string) error {
+	full, err := b.resolve("remove", name, false)
+	if err != nil {
+		return err
+	}
+	return b.fixErr(b.fsys.Remove(full), name)
+}
+
+// RemoveAll implements RemoveFS. The root itself can't be removed.
+func (b *basePath) RemoveAll(name string) error {
+	full, err := b.resolve("removeall", name, false)
+	if err != nil {
+		return err
+	}
+	if full == b.root {
+		return &iofs.PathError{Op: "removeall", Path: name, Err: iofs.ErrInvalid}
+	}
+	return b.fixErr(b.fsys.RemoveAll(full), name)
+}
+
+// Rename implements RenameFS
+func (b *basePath) Rename(oldpath string, newpath string) error {
+	oldFull, newFull, err := b.resolvePair("rename", oldpath, newpath)
+	if err != nil {
+		return err
+	}
+	return b.fixLinkErr(b.fsys.Rename(oldFull, newFull), oldpath, newpath)
+}
+
+// resolvePair resolves both names of a rename or link without following a final symbolic link
+func (b *basePath) resolvePair(op string, oldname string, newname string) (string, string, error) {
+	oldFull, err := b.resolve(op, oldname, false)
+	if err != nil {
+		return "", "", &os.LinkError{Op: op, Old: oldname, New: newname, Err: unwrap(err)}
+	}
+	newFull, err := b.resolve(op, newname, false)
+	if err != nil {
+		return "", "", &os.LinkError{Op: op, Old: oldname, New: newname, Err: unwrap(err)}
+	}
+	return oldFull, newFull, nil
+}
+
+// Symlink implements SymlinkFS. The target is stored as given, an absolute target must be under the root.
+func (b *basePath) Symlink(oldname string, newname string) error {
+	op := "symlink"
+	if _, err := b.segments(oldname); err != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+	full, err := b.resolve(op, newname, false)
+	if err != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: unwrap(err)}
+	}
+	return b.fixLinkErr(Symlink(b.fsys, oldname, full), oldname, newname)
+}
+
+// Link implements LinkFS
+func (b *basePath) Link(oldname string, newname string) error {
+	oldFull, newFull, err := b.resolvePair("link", oldname, newname)
+	if err != nil {
+		return err
+	}
+	return b.fixLinkErr(Link(b.fsys, oldFull, newFull), oldname, newname)
+}
+
+// Chmod implements ChangeFS
+func (b *basePath) Chmod(name string, mode iofs.FileMode) error {
+	full, err := b.resolve("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	return b.fixErr(Chmod(b.fsys, full, mode), name)
+}
+
+// Chtimes implements ChangeFS
+func (b *basePath) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	full, err := b.resolve("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	return b.fixErr(Chtimes(b.fsys, full, atime, mtime), name)
+}
+
+// Chown implements ChangeFS
+func (b *basePath) Chown(name string, uid, gid int) error {
+	full, err := b.resolve("chown", name, true)
+	if err != nil {
+		return err
+	}
+	return b.fixErr(Chown(b.fsys, full, uid, gid), name)
+}
+
+// Lchown implements ChangeFS
+func (b *basePath) Lchown(name string, uid, gid int) error {
+	full, err := b.resolve("lchown", name, false)
+	if err != nil {
+		return err
+	}
+	return b.fixErr(Lchown(b.fsys, full, uid, gid), name)
+}
+
+// basePathFile is a handle that reports the name relative to the root
+type basePathFile struct {
+	File
+	name string
+}
+
+// Name implements File
+func (f *basePathFile) Name() string {
+	return f.name
+}
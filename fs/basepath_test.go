@@ -0,0 +1,126 @@
+package fs_test
+
+import (
+	iofs "io/fs"
+	"testing"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/fs/fstesting"
+	"github.com/patrickhuber/go-xplat/platform"
+	"github.com/stretchr/testify/require"
+)
+
+// setupBasePath creates a base path rooted at /root/project of a memory file system. The memory file system
+// also contains /root/secret.txt outside of the root.
+func setupBasePath(t *testing.T, p platform.Platform) (fs.FS, fs.FS, *filepath.Processor) {
+	processor := newProcessor(p)
+	memory := fs.NewMemory(fs.WithProcessor(processor))
+
+	root, err := processor.Abs("/root")
+	require.NoError(t, err)
+	require.NoError(t, memory.MkdirAll(processor.Join(root, "project"), 0755))
+	require.NoError(t, memory.WriteFile(processor.Join(root, "secret.txt"), []byte("secret"), 0600))
+
+	basePath := fs.NewBasePath(memory, processor.Join(root, "project"), fs.WithBasePathProcessor(processor))
+	return basePath, memory, processor
+}
+
+func TestBasePathConformance(t *testing.T) {
+	for _, p := range []platform.Platform{platform.Linux, platform.Darwin, platform.Windows} {
+		t.Run(p.String(), func(t *testing.T) {
+			basePath, _, processor := setupBasePath(t, p)
+			root, err := processor.Abs("/root/project/conformance")
+			require.NoError(t, err)
+			fstesting.NewConformance(basePath, processor).Run(t, root)
+		})
+	}
+}
+
+func TestBasePathResolvesUnderRoot(t *testing.T) {
+	basePath, memory, _ := setupBasePath(t, platform.Linux)
+
+	require.NoError(t, basePath.MkdirAll("child", 0755))
+	require.NoError(t, basePath.WriteFile("child/file.txt", []byte("content"), 0644))
+
+	content, err := memory.ReadFile("/root/project/child/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+
+	for _, name := range []string{"child/../child/./file.txt", "/root/project/child/file.txt"} {
+		content, err = basePath.ReadFile(name)
+		require.NoError(t, err, name)
+		require.Equal(t, "content", string(content))
+	}
+
+	f, err := basePath.Open("child/file.txt")
+	require.NoError(t, err)
+	defer f.Close()
+	require.Equal(t, "child/file.txt", f.(fs.File).Name())
+
+	matches, err := basePath.Glob("*/*.txt")
+	require.NoError(t, err)
+	require.Equal(t, []string{"child/file.txt"}, matches)
+}
+
+func TestBasePathRejectsEscapes(t *testing.T) {
+	basePath, memory, _ := setupBasePath(t, platform.Linux)
+
+	require.NoError(t, fs.Symlink(memory, "../secret.txt", "/root/project/relative"))
+	require.NoError(t, fs.Symlink(memory, "/root/secret.txt", "/root/project/absolute"))
+	require.NoError(t, fs.Symlink(memory, "/root", "/root/project/parent"))
+
+	names := []string{
+		"../secret.txt",
+		"child/../../secret.txt",
+		"/root/secret.txt",
+		"/root/project/../secret.txt",
+		"relative",
+		"absolute",
+		"parent/secret.txt",
+	}
+	for _, name := range names {
+		_, err := basePath.ReadFile(name)
+		require.ErrorIs(t, err, fs.ErrPathEscapes, name)
+
+		var pathError *iofs.PathError
+		require.ErrorAs(t, err, &pathError)
+		require.Equal(t, name, pathError.Path)
+
+		require.ErrorIs(t, basePath.WriteFile(name, []byte("changed"), 0644), fs.ErrPathEscapes, name)
+	}
+
+	require.ErrorIs(t, basePath.Rename("../secret.txt", "stolen.txt"), fs.ErrPathEscapes)
+	require.ErrorIs(t, fs.Symlink(basePath, "/root/secret.txt", "link"), fs.ErrPathEscapes)
+
+	// links can still be removed and read without following them
+	target, err := fs.Readlink(basePath, "relative")
+	require.NoError(t, err)
+	require.Equal(t, "../secret.txt", target)
+	require.NoError(t, basePath.Remove("relative"))
+
+	content, err := memory.ReadFile("/root/secret.txt")
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(content))
+}
+
+func TestBasePathFollowsLinksUnderRoot(t *testing.T) {
+	basePath, memory, _ := setupBasePath(t, platform.Linux)
+
+	require.NoError(t, basePath.MkdirAll("data", 0755))
+	require.NoError(t, basePath.WriteFile("data/file.txt", []byte("content"), 0644))
+	require.NoError(t, fs.Symlink(basePath, "data", "relative"))
+	require.NoError(t, fs.Symlink(memory, "/root/project/data", "/root/project/absolute"))
+
+	for _, name := range []string{"relative/file.txt", "absolute/file.txt"} {
+		content, err := basePath.ReadFile(name)
+		require.NoError(t, err, name)
+		require.Equal(t, "content", string(content))
+	}
+
+	// the link is reported under its own name
+	info, err := basePath.Stat("relative")
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+	require.Equal(t, "relative", info.Name())
+}
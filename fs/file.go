@@ -3,48 +3,239 @@ package fs
 import (
 	"io"
 	"io/fs"
+	"os"
+	"syscall"
 	"testing/fstest"
 	"time"
 )
 
+// DirFile is a file that lists the entries of a directory with the paging semantics of *os.File
+type DirFile interface {
+	// ReadDir reads the directory and returns up to n entries. If n <= 0 all remaining entries are returned.
+	ReadDir(n int) ([]fs.DirEntry, error)
+	// Readdirnames reads the directory and returns up to n names. If n <= 0 all remaining names are returned.
+	Readdirnames(n int) ([]string, error)
+}
+
 type File interface {
 	fs.File
 	io.ReaderAt
 	io.Writer
 	io.WriterAt
+	io.StringWriter
 	io.Seeker
+	io.ReaderFrom
+	io.WriterTo
+	DirFile
+
+	// Name returns the name of the file as presented to Open
+	Name() string
+	// Truncate changes the size of the file. It does not change the offset.
+	Truncate(size int64) error
+	// Sync commits the contents of the file to stable storage
+	Sync() error
 }
 
+// MemoryStat is the system specific information of a memory file returned from FileInfo.Sys()
+type MemoryStat struct {
+	Uid        int
+	Gid        int
+	AccessTime time.Time
+	// Nlink is the number of names that refer to the file
+	Nlink int
+}
+
+// memoryStat returns the system specific information of the file, creating it if missing
+func memoryStat(file *fstest.MapFile) *MemoryStat {
+	stat, ok := file.Sys.(*MemoryStat)
+	if !ok {
+		stat = &MemoryStat{}
+		file.Sys = stat
+	}
+	return stat
+}
+
+// infoFile is a snapshot of a memory file's metadata. It is safe to use after the file changes.
 type infoFile struct {
-	name string
-	file *fstest.MapFile
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	sys     any
+}
+
+// newInfoFile creates a snapshot of the file. The caller must hold the memory lock.
+func newInfoFile(name string, file *fstest.MapFile) *infoFile {
+	sys := file.Sys
+	if stat, ok := sys.(*MemoryStat); ok {
+		clone := *stat
+		sys = &clone
+	}
+	return &infoFile{
+		name:    name,
+		size:    int64(len(file.Data)),
+		mode:    file.Mode,
+		modTime: file.ModTime,
+		sys:     sys,
+	}
 }
 
 func (i *infoFile) Name() string               { return i.name }
-func (i *infoFile) Size() int64                { return int64(len(i.file.Data)) }
-func (i *infoFile) Mode() fs.FileMode          { return i.file.Mode }
-func (i *infoFile) Type() fs.FileMode          { return i.file.Mode.Type() }
-func (i *infoFile) ModTime() time.Time         { return i.file.ModTime }
-func (i *infoFile) IsDir() bool                { return i.file.Mode&fs.ModeDir != 0 }
-func (i *infoFile) Sys() any                   { return i.file.Sys }
+func (i *infoFile) Size() int64                { return i.size }
+func (i *infoFile) Mode() fs.FileMode          { return i.mode }
+func (i *infoFile) Type() fs.FileMode          { return i.mode.Type() }
+func (i *infoFile) ModTime() time.Time         { return i.modTime }
+func (i *infoFile) IsDir() bool                { return i.mode&fs.ModeDir != 0 }
+func (i *infoFile) Sys() any                   { return i.sys }
 func (i *infoFile) Info() (fs.FileInfo, error) { return i, nil }
 
+// namedInfo is the info of a resolved entry reported under the name it was requested by. Wrappers that
+// follow a symbolic link before calling the wrapped file system would otherwise report the target name.
+type namedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i *namedInfo) Name() string { return i.name }
+
+// openFile is a handle to a memory file. All access to the file goes through the memory lock
+// so handles can be shared between goroutines.
 type openFile struct {
-	path string
-	infoFile
+	memory *memory
+	path   string
+	key    string
+	name   string
+	file   *fstest.MapFile
 	offset int64
+	flag   int
+	closed bool
+
+	// id orders the handle among the open handles and stack is where it was opened
+	id    uint64
+	stack []uintptr
+
+	// entries are the remaining directory entries, read on the first call to ReadDir
+	entries []fs.DirEntry
+	listed  bool
 }
 
 func (f *openFile) Stat() (fs.FileInfo, error) {
-	return f, nil
+	f.memory.mutex.RLock()
+	defer f.memory.mutex.RUnlock()
+
+	if err := f.checkClosed("stat"); err != nil {
+		return nil, err
+	}
+	return newInfoFile(f.name, f.file), nil
 }
 
+// ReadDir implements DirFile. If n > 0 at most n entries are returned and io.EOF is returned
+// at the end of the directory. If n <= 0 all remaining entries are returned.
+func (f *openFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.memory.mutex.Lock()
+	defer f.memory.mutex.Unlock()
+
+	op := "readdir"
+	if err := f.checkClosed(op); err != nil {
+		return nil, err
+	}
+	if !f.file.Mode.IsDir() {
+		return nil, &fs.PathError{Op: op, Path: f.path, Err: syscall.ENOTDIR}
+	}
+	if !f.listed {
+		f.entries = f.memory.readDir(f.key)
+		f.listed = true
+	}
+
+	count := len(f.entries)
+	if n > 0 && count == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < count {
+		count = n
+	}
+	entries := f.entries[:count]
+	f.entries = f.entries[count:]
+	return entries, nil
+}
+
+// Readdirnames implements DirFile and shares the position of ReadDir
+func (f *openFile) Readdirnames(n int) ([]string, error) {
+	entries, err := f.ReadDir(n)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, err
+}
+
+// Name implements File and returns the name as presented to Open
+func (f *openFile) Name() string {
+	return f.path
+}
+
+// Sync implements File. Memory files have no stable storage so there is nothing to flush.
+func (f *openFile) Sync() error {
+	f.memory.mutex.RLock()
+	defer f.memory.mutex.RUnlock()
+	return f.checkClosed("sync")
+}
+
+// Truncate implements File. Growing the file fills the new bytes with zeros.
+func (f *openFile) Truncate(size int64) error {
+	f.memory.mutex.Lock()
+	defer f.memory.mutex.Unlock()
+
+	op := "truncate"
+	if err := f.checkClosed(op); err != nil {
+		return err
+	}
+	if err := f.checkWrite(op); err != nil {
+		return err
+	}
+	if f.file.Mode&fs.ModeDir != 0 || size < 0 {
+		return &fs.PathError{Op: op, Path: f.path, Err: fs.ErrInvalid}
+	}
+	f.resize(size)
+	f.memory.touch(f.file)
+	return nil
+}
+
+// resize grows or shrinks the data to the size. The caller must hold the memory lock.
+func (f *openFile) resize(size int64) {
+	length := int64(len(f.file.Data))
+	if size <= length {
+		f.file.Data = f.file.Data[:size]
+		return
+	}
+	f.file.Data = append(f.file.Data, make([]byte, size-length)...)
+}
+
+// Close implements fs.File. Closing a handle twice returns fs.ErrClosed.
 func (f *openFile) Close() error {
+	f.memory.mutex.Lock()
+	defer f.memory.mutex.Unlock()
+
+	if err := f.checkClosed("close"); err != nil {
+		return err
+	}
+	f.closed = true
+	f.entries = nil
+	f.memory.release(f)
 	return nil
 }
 
 func (f *openFile) Read(b []byte) (int, error) {
+	f.memory.mutex.Lock()
+	defer f.memory.mutex.Unlock()
+
 	op := "read"
+	if err := f.checkClosed(op); err != nil {
+		return 0, err
+	}
+	if err := f.checkRead(op); err != nil {
+		return 0, err
+	}
 	if f.file.Mode&fs.ModeDir != 0 {
 		return 0, &fs.PathError{Op: op, Path: f.path, Err: fs.ErrInvalid}
 	}
@@ -60,6 +251,12 @@ func (f *openFile) Read(b []byte) (int, error) {
 }
 
 func (f *openFile) Seek(offset int64, whence int) (int64, error) {
+	f.memory.mutex.Lock()
+	defer f.memory.mutex.Unlock()
+
+	if err := f.checkClosed("seek"); err != nil {
+		return 0, err
+	}
 	switch whence {
 	case io.SeekStart:
 		// offset += 0
@@ -67,8 +264,11 @@ func (f *openFile) Seek(offset int64, whence int) (int64, error) {
 		offset += f.offset
 	case io.SeekEnd:
 		offset += int64(len(f.file.Data))
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrInvalid}
 	}
-	if offset < 0 || offset > int64(len(f.file.Data)) {
+	// seeking past the end is allowed, a later write fills the gap with zeros
+	if offset < 0 {
 		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrInvalid}
 	}
 	f.offset = offset
@@ -76,8 +276,21 @@ func (f *openFile) Seek(offset int64, whence int) (int64, error) {
 }
 
 func (f *openFile) ReadAt(b []byte, offset int64) (int, error) {
-	if offset < 0 || offset > int64(len(f.file.Data)) {
-		return 0, &fs.PathError{Op: "read", Path: f.path, Err: fs.ErrInvalid}
+	f.memory.mutex.RLock()
+	defer f.memory.mutex.RUnlock()
+
+	op := "read"
+	if err := f.checkClosed(op); err != nil {
+		return 0, err
+	}
+	if err := f.checkRead(op); err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: op, Path: f.path, Err: fs.ErrInvalid}
+	}
+	if offset >= int64(len(f.file.Data)) {
+		return 0, io.EOF
 	}
 	n := copy(b, f.file.Data[offset:])
 	if n < len(b) {
@@ -87,8 +300,21 @@ func (f *openFile) ReadAt(b []byte, offset int64) (int, error) {
 }
 
 func (f *openFile) Write(b []byte) (int, error) {
+	f.memory.mutex.Lock()
+	defer f.memory.mutex.Unlock()
+
 	op := "write"
-	written, err := f.WriteAt(b, f.offset)
+	if err := f.checkClosed(op); err != nil {
+		return 0, err
+	}
+	if err := f.checkWrite(op); err != nil {
+		return 0, err
+	}
+	// with O_APPEND every write goes to the end regardless of the offset
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.file.Data))
+	}
+	written, err := f.writeAt(b, f.offset)
 	if err != nil {
 		return 0, changeOp(err, op)
 	}
@@ -96,6 +322,49 @@ func (f *openFile) Write(b []byte) (int, error) {
 	return written, nil
 }
 
+// checkRead returns an error if the handle was not opened for reading
+func (f *openFile) checkRead(op string) error {
+	if accessMode(f.flag) == os.O_WRONLY {
+		return &fs.PathError{Op: op, Path: f.path, Err: syscall.EBADF}
+	}
+	return nil
+}
+
+// checkWrite returns an error if the handle was not opened for writing
+func (f *openFile) checkWrite(op string) error {
+	if accessMode(f.flag) == os.O_RDONLY {
+		return &fs.PathError{Op: op, Path: f.path, Err: syscall.EBADF}
+	}
+	return nil
+}
+
+// WriteString implements io.StringWriter
+func (f *openFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+// ReadFrom implements io.ReaderFrom. The memory lock is not held while reading from r
+// so r may be another handle of the same file system.
+func (f *openFile) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(writerOnly{f}, r)
+}
+
+// WriteTo implements io.WriterTo. The memory lock is not held while writing to w
+// so w may be another handle of the same file system.
+func (f *openFile) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, readerOnly{f})
+}
+
+// writerOnly hides ReadFrom so io.Copy does not call back into the file
+type writerOnly struct {
+	io.Writer
+}
+
+// readerOnly hides WriteTo so io.Copy does not call back into the file
+type readerOnly struct {
+	io.Reader
+}
+
 func changeOp(err error, op string) error {
 	perr, ok := err.(*fs.PathError)
 	if !ok {
@@ -106,7 +375,26 @@ func changeOp(err error, op string) error {
 }
 
 func (f *openFile) WriteAt(b []byte, offset int64) (int, error) {
-	op := "writeAt"
+	f.memory.mutex.Lock()
+	defer f.memory.mutex.Unlock()
+
+	op := "writeat"
+	if err := f.checkClosed(op); err != nil {
+		return 0, err
+	}
+	if err := f.checkWrite(op); err != nil {
+		return 0, err
+	}
+	// matches *os.File which rejects WriteAt on files opened with O_APPEND
+	if f.flag&os.O_APPEND != 0 {
+		return 0, &fs.PathError{Op: op, Path: f.path, Err: fs.ErrInvalid}
+	}
+	return f.writeAt(b, offset)
+}
+
+// writeAt writes the bytes at the offset. The caller must hold the memory lock.
+func (f *openFile) writeAt(b []byte, offset int64) (int, error) {
+	op := "writeat"
 	if f.file.Mode&fs.ModeDir != 0 {
 		return 0, &fs.PathError{Op: op, Path: f.path, Err: fs.ErrInvalid}
 	}
@@ -114,15 +402,13 @@ func (f *openFile) WriteAt(b []byte, offset int64) (int, error) {
 		return 0, &fs.PathError{Op: op, Path: f.path, Err: fs.ErrInvalid}
 	}
 
+	// writing past the end leaves a hole of zeros between the old end and the offset
+	end := offset + int64(len(b))
+	if end > int64(len(f.file.Data)) {
+		f.resize(end)
+	}
 	copy(f.file.Data[offset:], b)
-	f.file.Data = append(f.file.Data, b[min(len(b), len(f.file.Data)-int(offset)):]...)
+	f.memory.touch(f.file)
 
 	return len(b), nil
 }
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
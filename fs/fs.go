@@ -1,9 +1,15 @@
 package fs
 
 import (
+	"errors"
 	iofs "io/fs"
+	"os"
+	"time"
 )
 
+// ErrUnsupported is returned when a file system does not implement the capability interface an operation needs
+var ErrUnsupported = errors.New("operation not supported")
+
 type OpenFileFS interface {
 	OpenFile(name string, flag int, perm iofs.FileMode) (File, error)
 }
@@ -36,6 +42,37 @@ type CreateFS interface {
 	Create(path string) (File, error)
 }
 
+// SymlinkFS is a file system that supports symbolic links. Use the Symlink, Readlink and Lstat functions to call
+// it on a file system that may not implement it.
+type SymlinkFS interface {
+	// Symlink creates newname as a symbolic link to oldname
+	Symlink(oldname, newname string) error
+	// Readlink returns the destination of the named symbolic link
+	Readlink(name string) (string, error)
+	// Lstat returns a FileInfo describing the named file. If the file is a symbolic link, the returned FileInfo describes the symbolic link
+	Lstat(name string) (iofs.FileInfo, error)
+}
+
+// LinkFS is a file system that supports hard links. Use the Link function to call it on a file system that may
+// not implement it.
+type LinkFS interface {
+	// Link creates newname as a hard link to the oldname file
+	Link(oldname, newname string) error
+}
+
+// ChangeFS is a file system that supports changing file metadata. Use the Chmod, Chtimes, Chown and Lchown
+// functions to call it on a file system that may not implement it.
+type ChangeFS interface {
+	// Chmod changes the mode of the named file to mode. If the file is a symbolic link, it changes the mode of the link's target
+	Chmod(name string, mode iofs.FileMode) error
+	// Chtimes changes the access and modification times of the named file. A zero time value leaves the time unchanged
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+	// Chown changes the numeric uid and gid of the named file. A uid or gid of -1 means to not change that value
+	Chown(name string, uid, gid int) error
+	// Lchown changes the numeric uid and gid of the named file. If the file is a symbolic link, it changes the uid and gid of the link itself
+	Lchown(name string, uid, gid int) error
+}
+
 type FS interface {
 	iofs.FS
 	OpenFileFS
@@ -46,9 +83,75 @@ type FS interface {
 	ExistsFS
 	iofs.GlobFS
 	iofs.ReadFileFS
-	iofs.ReadFileFS
 	iofs.StatFS
 	iofs.SubFS
 	iofs.ReadDirFS
 	MakeDirFS
 }
+
+// Symlink creates newname as a symbolic link to oldname. If fsys does not implement SymlinkFS, Symlink returns ErrUnsupported.
+func Symlink(fsys iofs.FS, oldname, newname string) error {
+	if sym, ok := fsys.(SymlinkFS); ok {
+		return sym.Symlink(oldname, newname)
+	}
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrUnsupported}
+}
+
+// Readlink returns the destination of the named symbolic link. If fsys does not implement SymlinkFS, Readlink returns ErrUnsupported.
+func Readlink(fsys iofs.FS, name string) (string, error) {
+	if sym, ok := fsys.(SymlinkFS); ok {
+		return sym.Readlink(name)
+	}
+	return "", &iofs.PathError{Op: "readlink", Path: name, Err: ErrUnsupported}
+}
+
+// Lstat returns a FileInfo describing the named file without following a symbolic link. If fsys does not implement
+// SymlinkFS it can't contain symbolic links so Lstat calls Stat.
+func Lstat(fsys iofs.FS, name string) (iofs.FileInfo, error) {
+	if sym, ok := fsys.(SymlinkFS); ok {
+		return sym.Lstat(name)
+	}
+	return iofs.Stat(fsys, name)
+}
+
+// Chmod changes the mode of the named file. If fsys does not implement ChangeFS, Chmod returns ErrUnsupported.
+func Chmod(fsys iofs.FS, name string, mode iofs.FileMode) error {
+	if change, ok := fsys.(ChangeFS); ok {
+		return change.Chmod(name, mode)
+	}
+	return &iofs.PathError{Op: "chmod", Path: name, Err: ErrUnsupported}
+}
+
+// Chtimes changes the access and modification times of the named file. If fsys does not implement ChangeFS,
+// Chtimes returns ErrUnsupported.
+func Chtimes(fsys iofs.FS, name string, atime time.Time, mtime time.Time) error {
+	if change, ok := fsys.(ChangeFS); ok {
+		return change.Chtimes(name, atime, mtime)
+	}
+	return &iofs.PathError{Op: "chtimes", Path: name, Err: ErrUnsupported}
+}
+
+// Chown changes the numeric uid and gid of the named file. If fsys does not implement ChangeFS, Chown returns ErrUnsupported.
+func Chown(fsys iofs.FS, name string, uid, gid int) error {
+	if change, ok := fsys.(ChangeFS); ok {
+		return change.Chown(name, uid, gid)
+	}
+	return &iofs.PathError{Op: "chown", Path: name, Err: ErrUnsupported}
+}
+
+// Lchown changes the numeric uid and gid of the named file without following a symbolic link. If fsys does not
+// implement ChangeFS, Lchown returns ErrUnsupported.
+func Lchown(fsys iofs.FS, name string, uid, gid int) error {
+	if change, ok := fsys.(ChangeFS); ok {
+		return change.Lchown(name, uid, gid)
+	}
+	return &iofs.PathError{Op: "lchown", Path: name, Err: ErrUnsupported}
+}
+
+// Link creates newname as a hard link to the oldname file. If fsys does not implement LinkFS, Link returns ErrUnsupported.
+func Link(fsys iofs.FS, oldname, newname string) error {
+	if link, ok := fsys.(LinkFS); ok {
+		return link.Link(oldname, newname)
+	}
+	return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: ErrUnsupported}
+}
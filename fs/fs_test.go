@@ -0,0 +1,87 @@
+package fs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/fs/fstesting"
+	"github.com/patrickhuber/go-xplat/os"
+	"github.com/patrickhuber/go-xplat/platform"
+	"github.com/stretchr/testify/require"
+)
+
+// plainFS hides every capability interface of the wrapped file system, like a file system written outside this module
+type plainFS struct {
+	fs.FS
+}
+
+func setupPlain(t *testing.T) (fs.FS, *filepath.Processor) {
+	processor := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(platform.Linux)))
+	memory := fs.NewMemory(fs.WithProcessor(processor))
+	require.NoError(t, memory.MkdirAll("/gran", 0755))
+	require.NoError(t, memory.WriteFile("/gran/file.txt", []byte("file"), 0644))
+	return plainFS{memory}, processor
+}
+
+func TestPlainConformance(t *testing.T) {
+	fsys, processor := setupPlain(t)
+	fstesting.NewConformance(fsys, processor).Run(t, "/conformance")
+}
+
+func TestSymlinkUnsupported(t *testing.T) {
+	fsys, _ := setupPlain(t)
+	_, ok := fsys.(fs.SymlinkFS)
+	require.False(t, ok)
+
+	require.ErrorIs(t, fs.Symlink(fsys, "/gran/file.txt", "/gran/link"), fs.ErrUnsupported)
+	_, err := fs.Readlink(fsys, "/gran/file.txt")
+	require.ErrorIs(t, err, fs.ErrUnsupported)
+
+	// without symbolic links Lstat is Stat
+	info, err := fs.Lstat(fsys, "/gran/file.txt")
+	require.NoError(t, err)
+	require.True(t, info.Mode().IsRegular())
+
+	// wrappers forward the error of the wrapped file system
+	_, err = fs.Readlink(fs.NewBasePath(fsys, "/gran"), "file.txt")
+	require.ErrorIs(t, err, fs.ErrUnsupported)
+}
+
+func TestChangeUnsupported(t *testing.T) {
+	fsys, processor := setupPlain(t)
+	_, ok := fsys.(fs.ChangeFS)
+	require.False(t, ok)
+
+	require.ErrorIs(t, fs.Chmod(fsys, "/gran/file.txt", 0600), fs.ErrUnsupported)
+	now := time.Now()
+	require.ErrorIs(t, fs.Chtimes(fsys, "/gran/file.txt", now, now), fs.ErrUnsupported)
+	require.ErrorIs(t, fs.Chown(fsys, "/gran/file.txt", 0, 0), fs.ErrUnsupported)
+	require.ErrorIs(t, fs.Lchown(fsys, "/gran/file.txt", 0, 0), fs.ErrUnsupported)
+
+	// copy up still works when the upper layer can't change metadata
+	upper := plainFS{fs.NewMemory(fs.WithProcessor(processor))}
+	overlay := fs.NewOverlay(fsys, upper, fs.WithOverlayProcessor(processor))
+	require.NoError(t, overlay.WriteFile("/gran/file.txt", []byte("changed"), 0644))
+	require.ErrorIs(t, fs.Chmod(overlay, "/gran/file.txt", 0600), fs.ErrUnsupported)
+}
+
+func TestLinkUnsupported(t *testing.T) {
+	fsys, _ := setupPlain(t)
+	_, ok := fsys.(fs.LinkFS)
+	require.False(t, ok)
+
+	require.ErrorIs(t, fs.Link(fsys, "/gran/file.txt", "/gran/link.txt"), fs.ErrUnsupported)
+	require.ErrorIs(t, fs.Link(fs.NewReadOnly(fsys), "/gran/file.txt", "/gran/link.txt"), fs.ErrUnsupported)
+}
+
+func TestCapabilities(t *testing.T) {
+	for name, fsys := range map[string]fs.FS{"os": fs.NewOS(), "memory": fs.NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			require.Implements(t, (*fs.SymlinkFS)(nil), fsys)
+			require.Implements(t, (*fs.ChangeFS)(nil), fsys)
+			require.Implements(t, (*fs.LinkFS)(nil), fsys)
+		})
+	}
+}
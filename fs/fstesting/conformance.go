@@ -0,0 +1,1802 @@
+// Package fstesting provides a conformance suite that verifies an fs.FS implementation behaves like the OS file system
+// and file systems that inject faults and record calls to test error handling
+package fstesting
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// Conformance runs conformance tests against a file system. Paths are built with the processor so the same
+// tests can run against any platform.
+type Conformance struct {
+	fs   fs.FS
+	path *filepath.Processor
+}
+
+// File is a file name and content used to set up and verify conformance tests
+type File struct {
+	Name    string
+	Content []byte
+}
+
+// NewConformance creates a conformance test for the file system using the processor to build paths
+func NewConformance(fs fs.FS, path *filepath.Processor) *Conformance {
+	return &Conformance{
+		fs:   fs,
+		path: path,
+	}
+}
+
+// symlinkFS returns the file system as a SymlinkFS or skips the test if it does not support symbolic links
+func (c *Conformance) symlinkFS(t *testing.T) fs.SymlinkFS {
+	symlinks, ok := c.fs.(fs.SymlinkFS)
+	if !ok {
+		t.Skip("file system does not implement fs.SymlinkFS")
+	}
+	return symlinks
+}
+
+// changeFS returns the file system as a ChangeFS or skips the test if it can't change metadata
+func (c *Conformance) changeFS(t *testing.T) fs.ChangeFS {
+	change, ok := c.fs.(fs.ChangeFS)
+	if !ok {
+		t.Skip("file system does not implement fs.ChangeFS")
+	}
+	return change
+}
+
+// linkFS returns the file system as a LinkFS or skips the test if it does not support hard links
+func (c *Conformance) linkFS(t *testing.T) fs.LinkFS {
+	links, ok := c.fs.(fs.LinkFS)
+	if !ok {
+		t.Skip("file system does not implement fs.LinkFS")
+	}
+	return links
+}
+
+func (c *Conformance) TestMkdirCreatesRoot(t *testing.T, root string) {
+	err := c.fs.Mkdir(root, 0666)
+	require.NoError(t, err)
+	ok, err := c.fs.Exists(root)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func (c *Conformance) TestMkdirFailsWhenRootNotExists(t *testing.T, path string) {
+	err := c.fs.Mkdir(path, 0666)
+	require.NotNil(t, err)
+}
+
+func (c *Conformance) TestMkdirAllCreatesAllDirectories(t *testing.T, path string, expected []string) {
+	err := c.fs.MkdirAll(path, 0666)
+	require.NoError(t, err)
+	for _, p := range expected {
+		ok, err := c.fs.Exists(p)
+		require.NoError(t, err)
+		require.True(t, ok, "%s does not exist", p)
+	}
+}
+
+func (c *Conformance) TestWriteFile(t *testing.T, path string, name string, content string) {
+
+	require.NotNil(t, c.path)
+
+	filep := c.path.Join(path, name)
+	err := c.fs.MkdirAll(path, 0666)
+	require.NoError(t, err)
+
+	// create the file
+	err = c.fs.WriteFile(filep, []byte(content), 0600)
+	require.NoError(t, err)
+
+	// read the file
+	read, err := c.fs.ReadFile(filep)
+	require.NoError(t, err)
+	require.Equal(t, content, string(read))
+}
+
+func (c *Conformance) TestWrite(t *testing.T, folder string, name string, data []byte, offset int64, write []byte, expected []byte) {
+	require.NotNil(t, c.path)
+
+	err := c.fs.MkdirAll(folder, 0666)
+	require.NoError(t, err)
+
+	full := c.path.Join(folder, name)
+
+	f, err := c.fs.OpenFile(full, os.O_CREATE|os.O_RDWR, 0666)
+	require.NoError(t, err)
+
+	n, err := f.Write(data)
+	require.NoError(t, err)
+	require.Equal(t, len(data), n)
+
+	n64, err := f.Seek(offset, io.SeekStart)
+	require.NoError(t, err)
+	require.Equal(t, offset, n64)
+
+	n, err = f.Write(write)
+	require.NoError(t, err)
+	require.Equal(t, n, len(write))
+
+	require.Nil(t, f.Close())
+
+	content, err := c.fs.ReadFile(full)
+	require.NoError(t, err)
+	require.Equal(t, expected, content)
+}
+
+func (c *Conformance) TestReadDir(t *testing.T, path string, setup []File, expected []File) {
+	// both must equal
+	err := c.fs.MkdirAll(path, 0666)
+	require.NoError(t, err)
+
+	fileNameMap := map[string]File{}
+	for _, file := range expected {
+		fileNameMap[file.Name] = file
+	}
+	for _, file := range setup {
+		filep := c.path.Join(path, file.Name)
+		// get the directory name and create that
+		dir := c.path.Dir(filep)
+		err = c.fs.MkdirAll(dir, 0666)
+		require.NoError(t, err)
+
+		content := file.Content
+		err = c.fs.WriteFile(filep, content, 0600)
+		require.NoError(t, err)
+	}
+
+	// list the files
+	entries, err := c.fs.ReadDir(path)
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+
+	require.Equal(t, len(expected), len(entries))
+
+	// check the entry names and values
+	for _, entry := range entries {
+		require.Contains(t, fileNameMap, entry.Name())
+	}
+}
+
+func (c *Conformance) TestCanCreateFile(t *testing.T, path string, files []File) {
+	err := c.fs.MkdirAll(path, 0666)
+	require.NoError(t, err)
+
+	// create the files
+	for _, file := range files {
+		filep := c.path.Join(path, file.Name)
+		f, err := c.fs.Create(filep)
+		require.NoError(t, err)
+		require.NotNil(t, file)
+		io.Copy(f, bytes.NewBuffer(file.Content))
+		require.Nil(t, f.Close())
+	}
+}
+
+func (c *Conformance) TestCanWriteFile(t *testing.T, path string, files []File) {
+
+	err := c.fs.MkdirAll(path, 0666)
+	require.NoError(t, err)
+
+	for _, file := range files {
+		filep := c.path.Join(path, file.Name)
+
+		f, err := c.fs.Create(filep)
+		require.NoError(t, err)
+		require.NotNil(t, file)
+
+		_, err = f.Write(file.Content)
+		require.NoError(t, err)
+		require.Nil(t, f.Close())
+
+		ofile, err := c.fs.Open(filep)
+		require.NoError(t, err)
+
+		stat, err := ofile.Stat()
+		require.NoError(t, err)
+
+		buf := make([]byte, stat.Size())
+		_, err = ofile.Read(buf)
+		require.NoError(t, err)
+		require.Equal(t, file.Content, buf)
+		require.Nil(t, ofile.Close())
+	}
+}
+
+func (c *Conformance) TestWindowsWillNormalizePath(t *testing.T, folder string, file string) {
+
+	err := c.fs.MkdirAll(folder, 0666)
+	require.NoError(t, err)
+
+	err = c.fs.WriteFile(c.path.Join(folder, file), []byte("content"), 0666)
+	require.NoError(t, err)
+
+	lower := strings.ToLower(c.path.Join(folder, file))
+	ok, err := c.fs.Exists(lower)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func (c *Conformance) TestWindowsFileForwardAndBackwardSlash(t *testing.T, filePath string) {
+	dir := c.path.Dir(filePath)
+	err := c.fs.MkdirAll(dir, 0666)
+	require.NoError(t, err)
+
+	backPath := strings.ReplaceAll(filePath, "/", "\\")
+	err = c.fs.WriteFile(backPath, []byte("test"), 0666)
+	require.NoError(t, err)
+
+	exists, err := c.fs.Exists(filePath)
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+func (c *Conformance) TestOpenFileFailsWhenNotExists(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0666)
+	require.NoError(t, err)
+
+	_, err = c.fs.OpenFile(file, os.O_RDONLY, 0666)
+	require.NotNil(t, err)
+}
+
+func (c *Conformance) TestSymlinkIsFollowed(t *testing.T, folder string, target string, link string, content string) {
+	symlinks := c.symlinkFS(t)
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	targetPath := c.path.Join(folder, target)
+	linkPath := c.path.Join(folder, link)
+
+	err = c.fs.WriteFile(targetPath, []byte(content), 0644)
+	require.NoError(t, err)
+
+	err = symlinks.Symlink(targetPath, linkPath)
+	require.NoError(t, err)
+
+	read, err := c.fs.ReadFile(linkPath)
+	require.NoError(t, err)
+	require.Equal(t, content, string(read))
+
+	stat, err := c.fs.Stat(linkPath)
+	require.NoError(t, err)
+	require.True(t, stat.Mode().IsRegular())
+	require.Equal(t, int64(len(content)), stat.Size())
+	require.Equal(t, link, stat.Name())
+
+	lstat, err := symlinks.Lstat(linkPath)
+	require.NoError(t, err)
+	require.Equal(t, iofs.ModeSymlink, lstat.Mode().Type())
+
+	dest, err := symlinks.Readlink(linkPath)
+	require.NoError(t, err)
+	require.Equal(t, targetPath, dest)
+}
+
+func (c *Conformance) TestRelativeSymlinkToDirectory(t *testing.T, folder string, target string, link string, file string) {
+	symlinks := c.symlinkFS(t)
+	targetPath := c.path.Join(folder, target)
+	err := c.fs.MkdirAll(targetPath, 0777)
+	require.NoError(t, err)
+
+	err = c.fs.WriteFile(c.path.Join(targetPath, file), []byte("content"), 0644)
+	require.NoError(t, err)
+
+	linkPath := c.path.Join(folder, link)
+	err = symlinks.Symlink(target, linkPath)
+	require.NoError(t, err)
+
+	read, err := c.fs.ReadFile(c.path.Join(linkPath, file))
+	require.NoError(t, err)
+	require.Equal(t, "content", string(read))
+
+	entries, err := c.fs.ReadDir(linkPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, file, entries[0].Name())
+
+	stat, err := c.fs.Stat(linkPath)
+	require.NoError(t, err)
+	require.True(t, stat.IsDir())
+	require.Equal(t, link, stat.Name())
+}
+
+func (c *Conformance) TestReadlinkFailsWhenNotSymlink(t *testing.T, folder string, file string) {
+	symlinks := c.symlinkFS(t)
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	_, err = symlinks.Readlink(filePath)
+	require.Error(t, err)
+}
+
+func (c *Conformance) TestRemoveSymlinkKeepsTarget(t *testing.T, folder string, target string, link string) {
+	symlinks := c.symlinkFS(t)
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	targetPath := c.path.Join(folder, target)
+	linkPath := c.path.Join(folder, link)
+
+	err = c.fs.WriteFile(targetPath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	err = symlinks.Symlink(targetPath, linkPath)
+	require.NoError(t, err)
+
+	err = c.fs.Remove(linkPath)
+	require.NoError(t, err)
+
+	_, err = symlinks.Lstat(linkPath)
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	ok, err := c.fs.Exists(targetPath)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func (c *Conformance) TestSymlinkLoop(t *testing.T, folder string, first string, second string) {
+	symlinks := c.symlinkFS(t)
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	firstPath := c.path.Join(folder, first)
+	secondPath := c.path.Join(folder, second)
+
+	require.NoError(t, symlinks.Symlink(secondPath, firstPath))
+	require.NoError(t, symlinks.Symlink(firstPath, secondPath))
+
+	_, err = c.fs.Stat(firstPath)
+	require.ErrorIs(t, err, syscall.ELOOP)
+
+	_, err = c.fs.ReadFile(secondPath)
+	require.ErrorIs(t, err, syscall.ELOOP)
+
+	_, err = symlinks.Lstat(firstPath)
+	require.NoError(t, err)
+}
+
+func (c *Conformance) TestChmod(t *testing.T, folder string, file string, mode iofs.FileMode) {
+	change := c.changeFS(t)
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0600)
+	require.NoError(t, err)
+
+	err = change.Chmod(filePath, mode)
+	require.NoError(t, err)
+
+	stat, err := c.fs.Stat(filePath)
+	require.NoError(t, err)
+	require.Equal(t, mode, stat.Mode().Perm())
+	require.True(t, stat.Mode().IsRegular())
+}
+
+func (c *Conformance) TestChtimes(t *testing.T, folder string, file string, mtime time.Time) {
+	change := c.changeFS(t)
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0600)
+	require.NoError(t, err)
+
+	err = change.Chtimes(filePath, mtime, mtime)
+	require.NoError(t, err)
+
+	stat, err := c.fs.Stat(filePath)
+	require.NoError(t, err)
+	require.True(t, mtime.Equal(stat.ModTime()), "expected %v found %v", mtime, stat.ModTime())
+}
+
+func (c *Conformance) TestChmodFailsWhenNotExists(t *testing.T, folder string, file string) {
+	change := c.changeFS(t)
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	err = change.Chmod(c.path.Join(folder, file), 0755)
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+func (c *Conformance) TestRenameDirectoryMovesChildren(t *testing.T, folder string, from string, to string, files []File) {
+	fromPath := c.path.Join(folder, from)
+	toPath := c.path.Join(folder, to)
+	err := c.fs.MkdirAll(fromPath, 0777)
+	require.NoError(t, err)
+
+	for _, file := range files {
+		filep := c.path.Join(fromPath, file.Name)
+		err = c.fs.MkdirAll(c.path.Dir(filep), 0777)
+		require.NoError(t, err)
+		err = c.fs.WriteFile(filep, file.Content, 0644)
+		require.NoError(t, err)
+	}
+
+	err = c.fs.Rename(fromPath, toPath)
+	require.NoError(t, err)
+
+	ok, err := c.fs.Exists(fromPath)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	for _, file := range files {
+		ok, err := c.fs.Exists(c.path.Join(fromPath, file.Name))
+		require.NoError(t, err)
+		require.False(t, ok, "%s was not moved", file.Name)
+
+		content, err := c.fs.ReadFile(c.path.Join(toPath, file.Name))
+		require.NoError(t, err)
+		require.Equal(t, file.Content, content)
+	}
+}
+
+func (c *Conformance) TestRenameDirectoryIntoItselfFails(t *testing.T, folder string, child string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	err = c.fs.Rename(folder, c.path.Join(folder, child))
+	require.Error(t, err)
+
+	ok, err := c.fs.Exists(folder)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func (c *Conformance) TestRenameFailsWhenDestinationParentNotExists(t *testing.T, folder string, file string, missing string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	err = c.fs.Rename(filePath, c.path.Join(folder, missing, file))
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	ok, err := c.fs.Exists(filePath)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func (c *Conformance) TestRenameOverwritesFile(t *testing.T, folder string, from string, to string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	fromPath := c.path.Join(folder, from)
+	toPath := c.path.Join(folder, to)
+	require.NoError(t, c.fs.WriteFile(fromPath, []byte("from"), 0644))
+	require.NoError(t, c.fs.WriteFile(toPath, []byte("to"), 0644))
+
+	err = c.fs.Rename(fromPath, toPath)
+	require.NoError(t, err)
+
+	content, err := c.fs.ReadFile(toPath)
+	require.NoError(t, err)
+	require.Equal(t, "from", string(content))
+}
+
+func (c *Conformance) TestRenameFailsWhenFileExists(t *testing.T, folder string, from string, to string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	fromPath := c.path.Join(folder, from)
+	toPath := c.path.Join(folder, to)
+	require.NoError(t, c.fs.WriteFile(fromPath, []byte("from"), 0644))
+	require.NoError(t, c.fs.WriteFile(toPath, []byte("to"), 0644))
+
+	err = c.fs.Rename(fromPath, toPath)
+	require.ErrorIs(t, err, iofs.ErrExist)
+
+	content, err := c.fs.ReadFile(toPath)
+	require.NoError(t, err)
+	require.Equal(t, "to", string(content))
+}
+
+func (c *Conformance) TestWriteFileFailsWhenParentNotExists(t *testing.T, folder string, missing string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, missing, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	var pathError *iofs.PathError
+	require.ErrorAs(t, err, &pathError)
+}
+
+func (c *Conformance) TestCreateFailsWhenParentNotExists(t *testing.T, folder string, missing string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	_, err = c.fs.Create(c.path.Join(folder, missing, file))
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	var pathError *iofs.PathError
+	require.ErrorAs(t, err, &pathError)
+}
+
+func (c *Conformance) TestWriteFailsWhenDirectory(t *testing.T, folder string, dir string) {
+	dirPath := c.path.Join(folder, dir)
+	err := c.fs.MkdirAll(dirPath, 0777)
+	require.NoError(t, err)
+
+	requireIsDir := func(err error) {
+		require.Error(t, err)
+		if !c.path.OS.Platform().IsWindows() {
+			require.ErrorIs(t, err, syscall.EISDIR)
+		}
+	}
+
+	f, err := c.fs.Create(dirPath)
+	if err == nil {
+		f.Close()
+	}
+	requireIsDir(err)
+
+	requireIsDir(c.fs.WriteFile(dirPath, []byte("data"), 0644))
+
+	f, err = c.fs.OpenFile(dirPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err == nil {
+		f.Close()
+	}
+	requireIsDir(err)
+
+	stat, err := c.fs.Stat(dirPath)
+	require.NoError(t, err)
+	require.True(t, stat.IsDir())
+}
+
+func (c *Conformance) TestOpenFileCreateFailsWhenParentNotExists(t *testing.T, folder string, missing string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	_, err = c.fs.OpenFile(c.path.Join(folder, missing, file), os.O_CREATE|os.O_RDWR, 0644)
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	var pathError *iofs.PathError
+	require.ErrorAs(t, err, &pathError)
+}
+
+func (c *Conformance) TestWriteFileFailsWhenParentIsFile(t *testing.T, folder string, parent string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	parentPath := c.path.Join(folder, parent)
+	err = c.fs.WriteFile(parentPath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	err = c.fs.WriteFile(c.path.Join(parentPath, file), []byte("content"), 0644)
+	require.ErrorIs(t, err, syscall.ENOTDIR)
+
+	var pathError *iofs.PathError
+	require.ErrorAs(t, err, &pathError)
+}
+
+func (c *Conformance) TestMkdirFailsWhenExists(t *testing.T, folder string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	err = c.fs.Mkdir(folder, 0777)
+	require.ErrorIs(t, err, iofs.ErrExist)
+}
+
+func (c *Conformance) TestMkdirAllIsIdempotent(t *testing.T, folder string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	err = c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+}
+
+func (c *Conformance) TestMkdirAllFailsWhenFileInPath(t *testing.T, folder string, file string, child string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	err = c.fs.MkdirAll(c.path.Join(filePath, child), 0777)
+	require.Error(t, err)
+}
+
+func (c *Conformance) TestCreateTruncatesExistingFile(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	f, err := c.fs.Create(filePath)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := c.fs.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Empty(t, content)
+}
+
+func (c *Conformance) TestOpenFileTruncate(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	f, err := c.fs.OpenFile(filePath, os.O_WRONLY|os.O_TRUNC, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("new"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := c.fs.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "new", string(content))
+}
+
+func (c *Conformance) TestOpenFileAppend(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	f, err := c.fs.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(" appended"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := c.fs.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "content appended", string(content))
+}
+
+func (c *Conformance) TestSeek(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("0123456789"), 0644)
+	require.NoError(t, err)
+
+	f, err := c.fs.OpenFile(filePath, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	type test struct {
+		offset   int64
+		whence   int
+		expected int64
+		read     string
+	}
+	tests := []test{
+		{offset: 2, whence: io.SeekStart, expected: 2, read: "23"},
+		{offset: 2, whence: io.SeekCurrent, expected: 6, read: "67"},
+		{offset: -3, whence: io.SeekEnd, expected: 7, read: "78"},
+		{offset: 0, whence: io.SeekEnd, expected: 10, read: ""},
+	}
+	for i, test := range tests {
+		pos, err := f.Seek(test.offset, test.whence)
+		require.NoError(t, err, "test [%d] failed", i)
+		require.Equal(t, test.expected, pos, "test [%d] failed", i)
+
+		buf := make([]byte, 2)
+		n, err := f.Read(buf)
+		if test.read == "" {
+			require.ErrorIs(t, err, io.EOF, "test [%d] failed", i)
+			continue
+		}
+		require.NoError(t, err, "test [%d] failed", i)
+		require.Equal(t, test.read, string(buf[:n]), "test [%d] failed", i)
+	}
+
+	_, err = f.Seek(-1, io.SeekStart)
+	require.Error(t, err)
+}
+
+func (c *Conformance) TestReadAt(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("0123456789"), 0644)
+	require.NoError(t, err)
+
+	f, err := c.fs.OpenFile(filePath, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	buf := make([]byte, 3)
+	n, err := f.ReadAt(buf, 4)
+	require.NoError(t, err)
+	require.Equal(t, "456", string(buf[:n]))
+
+	// reading past the end returns the bytes read and io.EOF
+	n, err = f.ReadAt(buf, 8)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, "89", string(buf[:n]))
+}
+
+func (c *Conformance) TestStat(t *testing.T, folder string, file string, content string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte(content), 0644)
+	require.NoError(t, err)
+
+	stat, err := c.fs.Stat(filePath)
+	require.NoError(t, err)
+	require.Equal(t, file, stat.Name())
+	require.Equal(t, int64(len(content)), stat.Size())
+	require.False(t, stat.IsDir())
+	require.True(t, stat.Mode().IsRegular())
+
+	stat, err = c.fs.Stat(folder)
+	require.NoError(t, err)
+	require.True(t, stat.IsDir())
+
+	_, err = c.fs.Stat(c.path.Join(folder, "missing"))
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+func (c *Conformance) TestExists(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	ok, err := c.fs.Exists(filePath)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	ok, err = c.fs.Exists(filePath)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func (c *Conformance) TestRenameFile(t *testing.T, folder string, from string, to string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	fromPath := c.path.Join(folder, from)
+	toPath := c.path.Join(folder, to)
+	require.NoError(t, c.fs.WriteFile(fromPath, []byte("content"), 0644))
+
+	err = c.fs.Rename(fromPath, toPath)
+	require.NoError(t, err)
+
+	ok, err := c.fs.Exists(fromPath)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	content, err := c.fs.ReadFile(toPath)
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+}
+
+func (c *Conformance) TestRenameFailsWhenNotExists(t *testing.T, folder string, from string, to string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	err = c.fs.Rename(c.path.Join(folder, from), c.path.Join(folder, to))
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+func (c *Conformance) TestRemoveFile(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	require.NoError(t, c.fs.WriteFile(filePath, []byte("content"), 0644))
+
+	err = c.fs.Remove(filePath)
+	require.NoError(t, err)
+
+	ok, err := c.fs.Exists(filePath)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func (c *Conformance) TestRemoveFailsWhenNotExists(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	err = c.fs.Remove(c.path.Join(folder, file))
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+func (c *Conformance) TestRemoveEmptyDirectory(t *testing.T, folder string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	err = c.fs.Remove(folder)
+	require.NoError(t, err)
+
+	ok, err := c.fs.Exists(folder)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func (c *Conformance) TestRemoveFailsWhenDirectoryNotEmpty(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	require.NoError(t, c.fs.WriteFile(filePath, []byte("content"), 0644))
+
+	err = c.fs.Remove(folder)
+	require.Error(t, err)
+
+	ok, err := c.fs.Exists(filePath)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func (c *Conformance) TestRemoveAll(t *testing.T, folder string, files []File) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	for _, file := range files {
+		filep := c.path.Join(folder, file.Name)
+		require.NoError(t, c.fs.MkdirAll(c.path.Dir(filep), 0777))
+		require.NoError(t, c.fs.WriteFile(filep, file.Content, 0644))
+	}
+
+	err = c.fs.RemoveAll(folder)
+	require.NoError(t, err)
+
+	ok, err := c.fs.Exists(folder)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	for _, file := range files {
+		ok, err := c.fs.Exists(c.path.Join(folder, file.Name))
+		require.NoError(t, err)
+		require.False(t, ok, "%s was not removed", file.Name)
+	}
+}
+
+func (c *Conformance) TestRemoveAllSucceedsWhenNotExists(t *testing.T, folder string, missing string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	err = c.fs.RemoveAll(c.path.Join(folder, missing))
+	require.NoError(t, err)
+
+	err = c.fs.RemoveAll(c.path.Join(folder, missing, missing))
+	require.NoError(t, err)
+}
+
+// TestRenameExistingFile verifies the platform overwrite rules. POSIX platforms replace the destination
+// and Windows returns an error.
+func (c *Conformance) TestRenameExistingFile(t *testing.T, folder string, from string, to string) {
+	if c.path.OS.Platform().IsWindows() {
+		c.TestRenameFailsWhenFileExists(t, folder, from, to)
+		return
+	}
+	c.TestRenameOverwritesFile(t, folder, from, to)
+}
+
+// Run runs the conformance suite inside the root directory. Each test runs as a subtest in its own directory.
+// Symbolic link and mode tests are skipped on Windows because they depend on user privileges.
+func (c *Conformance) Run(t *testing.T, root string) {
+	require.NoError(t, c.fs.MkdirAll(root, 0777))
+	windows := c.path.OS.Platform().IsWindows()
+
+	run := func(name string, test func(t *testing.T, dir string)) {
+		t.Run(name, func(t *testing.T) {
+			test(t, c.path.Join(root, name))
+			RequireNoOpenHandles(t, c.fs)
+		})
+	}
+	files := []File{
+		{Name: "one.txt", Content: []byte("one")},
+		{Name: "two.txt", Content: []byte("two")},
+		{Name: c.path.Join("sub", "three.txt"), Content: []byte("three")},
+		{Name: c.path.Join("sub", "deeper", "four.txt"), Content: []byte("four")},
+	}
+
+	// mkdir
+	run("Mkdir", func(t *testing.T, dir string) {
+		c.TestMkdirAllIsIdempotent(t, dir)
+		c.TestMkdirCreatesRoot(t, c.path.Join(dir, "child"))
+	})
+	run("MkdirFailsWhenParentNotExists", func(t *testing.T, dir string) {
+		require.NoError(t, c.fs.MkdirAll(dir, 0777))
+		c.TestMkdirFailsWhenRootNotExists(t, c.path.Join(dir, "missing", "child"))
+	})
+	run("MkdirFailsWhenExists", func(t *testing.T, dir string) {
+		c.TestMkdirFailsWhenExists(t, dir)
+	})
+	run("MkdirAll", func(t *testing.T, dir string) {
+		c.TestMkdirAllCreatesAllDirectories(t, c.path.Join(dir, "gran", "parent", "child"), []string{
+			dir,
+			c.path.Join(dir, "gran"),
+			c.path.Join(dir, "gran", "parent"),
+			c.path.Join(dir, "gran", "parent", "child"),
+		})
+	})
+	run("MkdirAllFailsWhenFileInPath", func(t *testing.T, dir string) {
+		c.TestMkdirAllFailsWhenFileInPath(t, dir, "file.txt", "child")
+	})
+
+	// create, open and write
+	run("WriteFile", func(t *testing.T, dir string) {
+		c.TestWriteFile(t, dir, "file.txt", "file")
+	})
+	run("WriteFileFailsWhenParentNotExists", func(t *testing.T, dir string) {
+		c.TestWriteFileFailsWhenParentNotExists(t, dir, "missing", "file.txt")
+	})
+	if !windows {
+		run("WriteFileFailsWhenParentIsFile", func(t *testing.T, dir string) {
+			c.TestWriteFileFailsWhenParentIsFile(t, dir, "file.txt", "child.txt")
+		})
+	}
+	run("CreateFile", func(t *testing.T, dir string) {
+		c.TestCanCreateFile(t, dir, files[:2])
+		c.TestCanWriteFile(t, dir, files[:2])
+	})
+	run("CreateTruncatesExistingFile", func(t *testing.T, dir string) {
+		c.TestCreateTruncatesExistingFile(t, dir, "file.txt")
+	})
+	run("CreateFailsWhenParentNotExists", func(t *testing.T, dir string) {
+		c.TestCreateFailsWhenParentNotExists(t, dir, "missing", "file.txt")
+	})
+	run("WriteFailsWhenDirectory", func(t *testing.T, dir string) {
+		c.TestWriteFailsWhenDirectory(t, dir, "child")
+	})
+	run("OpenFileFailsWhenNotExists", func(t *testing.T, dir string) {
+		c.TestOpenFileFailsWhenNotExists(t, dir, c.path.Join(dir, "missing.txt"))
+	})
+	run("OpenFileCreateFailsWhenParentNotExists", func(t *testing.T, dir string) {
+		c.TestOpenFileCreateFailsWhenParentNotExists(t, dir, "missing", "file.txt")
+	})
+	run("OpenFileTruncate", func(t *testing.T, dir string) {
+		c.TestOpenFileTruncate(t, dir, "file.txt")
+	})
+	run("OpenFileAppend", func(t *testing.T, dir string) {
+		c.TestOpenFileAppend(t, dir, "file.txt")
+	})
+	run("WriteCanGrow", func(t *testing.T, dir string) {
+		c.TestWrite(t, dir, "grow.txt",
+			[]byte("this is test data"),
+			7,
+			[]byte(" more data than expected"),
+			[]byte("this is more data than expected"))
+	})
+	run("WriteCanOverwriteMiddle", func(t *testing.T, dir string) {
+		c.TestWrite(t, dir, "middle.txt",
+			[]byte("this is test data"),
+			8,
+			[]byte("also"),
+			[]byte("this is also data"))
+	})
+	run("WriteCanOverwriteEnd", func(t *testing.T, dir string) {
+		c.TestWrite(t, dir, "end.txt",
+			[]byte("this is test data"),
+			13,
+			[]byte("info"),
+			[]byte("this is test info"))
+	})
+	run("Seek", func(t *testing.T, dir string) {
+		c.TestSeek(t, dir, "file.txt")
+	})
+	run("ReadAt", func(t *testing.T, dir string) {
+		c.TestReadAt(t, dir, "file.txt")
+	})
+
+	// metadata
+	run("Stat", func(t *testing.T, dir string) {
+		c.TestStat(t, dir, "file.txt", "content")
+	})
+	run("Exists", func(t *testing.T, dir string) {
+		c.TestExists(t, dir, "file.txt")
+	})
+	run("Chtimes", func(t *testing.T, dir string) {
+		c.TestChtimes(t, dir, "file.txt", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	})
+	run("ChmodFailsWhenNotExists", func(t *testing.T, dir string) {
+		c.TestChmodFailsWhenNotExists(t, dir, "missing.txt")
+	})
+	if !windows {
+		run("Chmod", func(t *testing.T, dir string) {
+			c.TestChmod(t, dir, "tool", 0755)
+		})
+	}
+
+	// read dir
+	run("ReadDir", func(t *testing.T, dir string) {
+		c.TestReadDir(t, dir, files, []File{
+			{Name: "one.txt"},
+			{Name: "two.txt"},
+			{Name: "sub"},
+		})
+	})
+
+	run("ReadDirIsSorted", func(t *testing.T, dir string) {
+		c.TestReadDirIsSorted(t, dir, []string{"b.txt", "c.txt", "a.txt", "d.txt"})
+	})
+	run("WriteAtPastEnd", func(t *testing.T, dir string) {
+		c.TestWriteAtPastEnd(t, dir, "file.txt")
+	})
+	run("SeekPastEnd", func(t *testing.T, dir string) {
+		c.TestSeekPastEnd(t, dir, "file.txt")
+	})
+	run("Truncate", func(t *testing.T, dir string) {
+		c.TestTruncate(t, dir, "file.txt")
+	})
+	run("FileNameAndSync", func(t *testing.T, dir string) {
+		c.TestFileNameAndSync(t, dir, "file.txt")
+	})
+	run("ReadFromWriteTo", func(t *testing.T, dir string) {
+		c.TestReadFromWriteTo(t, dir, "source.txt", "target.txt", "content")
+	})
+	run("OpenFileExclusive", func(t *testing.T, dir string) {
+		c.TestOpenFileExclusive(t, dir, "file.lock")
+	})
+	run("OpenFileFailsWhenNotExistsWithoutCreate", func(t *testing.T, dir string) {
+		c.TestOpenFileFailsWhenNotExistsWithoutCreate(t, dir, "missing.txt")
+	})
+	run("OpenFileReadOnlyRejectsWrite", func(t *testing.T, dir string) {
+		c.TestOpenFileReadOnlyRejectsWrite(t, dir, "file.txt")
+	})
+	run("OpenFileWriteOnlyRejectsRead", func(t *testing.T, dir string) {
+		c.TestOpenFileWriteOnlyRejectsRead(t, dir, "file.txt")
+	})
+	run("OpenFileAppendIgnoresSeek", func(t *testing.T, dir string) {
+		c.TestOpenFileAppendIgnoresSeek(t, dir, "file.txt")
+	})
+	run("OpenFileSync", func(t *testing.T, dir string) {
+		c.TestOpenFileSync(t, dir, "file.txt")
+	})
+	run("UseAfterClose", func(t *testing.T, dir string) {
+		c.TestUseAfterClose(t, dir, "file.txt")
+	})
+	run("RemoveOpenFile", func(t *testing.T, dir string) {
+		c.TestRemoveOpenFile(t, dir, "file.txt", "content")
+	})
+	run("RenameOpenFile", func(t *testing.T, dir string) {
+		c.TestRenameOpenFile(t, dir, "source.txt", "target.txt", "content")
+	})
+	if c.path.Comparison.IgnoresCase() {
+		run("CasePreserving", func(t *testing.T, dir string) {
+			c.TestCasePreserving(t, dir, "MixedCase", "ReadMe.Txt")
+		})
+	}
+	if c.path.Comparison.IgnoresNormalization() {
+		run("NormalizationInsensitive", func(t *testing.T, dir string) {
+			c.TestNormalizationInsensitive(t, dir)
+		})
+	}
+	run("LinkSharesData", func(t *testing.T, dir string) {
+		c.TestLinkSharesData(t, dir, "file.txt", "link.txt")
+	})
+	run("LinkFails", func(t *testing.T, dir string) {
+		c.TestLinkFails(t, dir, "file.txt", "dir")
+	})
+	run("DirFilePaging", func(t *testing.T, dir string) {
+		c.TestDirFilePaging(t, dir, []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"})
+	})
+	run("DirFileFailsWhenNotDirectory", func(t *testing.T, dir string) {
+		c.TestDirFileFailsWhenNotDirectory(t, dir, "file.txt")
+	})
+	run("TestFS", func(t *testing.T, dir string) {
+		c.TestFS(t, dir, files)
+	})
+
+	// rename
+	run("RenameFile", func(t *testing.T, dir string) {
+		c.TestRenameFile(t, dir, "from.txt", "to.txt")
+	})
+	run("RenameFailsWhenNotExists", func(t *testing.T, dir string) {
+		c.TestRenameFailsWhenNotExists(t, dir, "from.txt", "to.txt")
+	})
+	run("RenameExistingFile", func(t *testing.T, dir string) {
+		c.TestRenameExistingFile(t, dir, "from.txt", "to.txt")
+	})
+	run("RenameDirectoryMovesChildren", func(t *testing.T, dir string) {
+		c.TestRenameDirectoryMovesChildren(t, dir, "from", "to", files)
+	})
+	run("RenameDirectoryIntoItselfFails", func(t *testing.T, dir string) {
+		c.TestRenameDirectoryIntoItselfFails(t, dir, "child")
+	})
+	run("RenameFailsWhenDestinationParentNotExists", func(t *testing.T, dir string) {
+		c.TestRenameFailsWhenDestinationParentNotExists(t, dir, "file.txt", "missing")
+	})
+
+	// remove
+	run("RemoveFile", func(t *testing.T, dir string) {
+		c.TestRemoveFile(t, dir, "file.txt")
+	})
+	run("RemoveFailsWhenNotExists", func(t *testing.T, dir string) {
+		c.TestRemoveFailsWhenNotExists(t, dir, "missing.txt")
+	})
+	run("RemoveEmptyDirectory", func(t *testing.T, dir string) {
+		c.TestRemoveEmptyDirectory(t, dir)
+	})
+	run("RemoveFailsWhenDirectoryNotEmpty", func(t *testing.T, dir string) {
+		c.TestRemoveFailsWhenDirectoryNotEmpty(t, dir, "file.txt")
+	})
+	run("RemoveAll", func(t *testing.T, dir string) {
+		c.TestRemoveAll(t, dir, files)
+	})
+	run("RemoveAllSucceedsWhenNotExists", func(t *testing.T, dir string) {
+		c.TestRemoveAllSucceedsWhenNotExists(t, dir, "missing")
+	})
+
+	// symbolic links
+	if !windows {
+		run("SymlinkIsFollowed", func(t *testing.T, dir string) {
+			c.TestSymlinkIsFollowed(t, dir, "target.txt", "link.txt", "content")
+		})
+		run("RelativeSymlinkToDirectory", func(t *testing.T, dir string) {
+			c.TestRelativeSymlinkToDirectory(t, dir, "1.0.0", "current", "tool.txt")
+		})
+		run("ReadlinkFailsWhenNotSymlink", func(t *testing.T, dir string) {
+			c.TestReadlinkFailsWhenNotSymlink(t, dir, "file.txt")
+		})
+		run("RemoveSymlinkKeepsTarget", func(t *testing.T, dir string) {
+			c.TestRemoveSymlinkKeepsTarget(t, dir, "target.txt", "link.txt")
+		})
+		run("SymlinkLoop", func(t *testing.T, dir string) {
+			c.TestSymlinkLoop(t, dir, "first", "second")
+		})
+	}
+}
+
+func (c *Conformance) TestReadDirIsSorted(t *testing.T, folder string, names []string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	for _, name := range names {
+		err = c.fs.WriteFile(c.path.Join(folder, name), []byte(name), 0644)
+		require.NoError(t, err)
+	}
+
+	entries, err := c.fs.ReadDir(folder)
+	require.NoError(t, err)
+
+	var actual []string
+	for _, entry := range entries {
+		actual = append(actual, entry.Name())
+	}
+	require.True(t, sort.StringsAreSorted(actual), "entries are not sorted %v", actual)
+	require.ElementsMatch(t, names, actual)
+}
+
+// TestFS writes the files to the folder and runs testing/fstest.TestFS against the file system returned from Sub(folder)
+func (c *Conformance) TestFS(t *testing.T, folder string, files []File) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	var expected []string
+	for _, file := range files {
+		filep := c.path.Join(folder, file.Name)
+		require.NoError(t, c.fs.MkdirAll(c.path.Dir(filep), 0777))
+		require.NoError(t, c.fs.WriteFile(filep, file.Content, 0644))
+
+		// expected files use io/fs names
+		name, err := c.path.Rel(folder, filep)
+		require.NoError(t, err)
+		expected = append(expected, strings.ReplaceAll(name, string(c.path.Separator), "/"))
+	}
+
+	sub, err := c.fs.Sub(folder)
+	require.NoError(t, err)
+	require.NoError(t, fstest.TestFS(sub, expected...))
+}
+
+func (c *Conformance) TestDirFilePaging(t *testing.T, folder string, names []string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	for _, name := range names {
+		err = c.fs.WriteFile(c.path.Join(folder, name), []byte(name), 0644)
+		require.NoError(t, err)
+	}
+
+	// read the entries in pages of two
+	f, err := c.fs.OpenFile(folder, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	var actual []string
+	for {
+		entries, err := f.ReadDir(2)
+		if errors.Is(err, io.EOF) {
+			require.Empty(t, entries)
+			break
+		}
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(entries), 2)
+		for _, entry := range entries {
+			actual = append(actual, entry.Name())
+		}
+	}
+	require.NoError(t, f.Close())
+	require.ElementsMatch(t, names, actual)
+
+	// read the names in pages of two
+	f, err = c.fs.OpenFile(folder, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	actual = nil
+	for {
+		page, err := f.Readdirnames(2)
+		if errors.Is(err, io.EOF) {
+			require.Empty(t, page)
+			break
+		}
+		require.NoError(t, err)
+		actual = append(actual, page...)
+	}
+	require.NoError(t, f.Close())
+	require.ElementsMatch(t, names, actual)
+
+	// read everything at once and continue where the last call left off
+	f, err = c.fs.OpenFile(folder, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	first, err := f.Readdirnames(1)
+	require.NoError(t, err)
+	require.Len(t, first, 1)
+
+	rest, err := f.ReadDir(-1)
+	require.NoError(t, err)
+	require.Len(t, rest, len(names)-1)
+
+	rest, err = f.ReadDir(-1)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+}
+
+func (c *Conformance) TestDirFileFailsWhenNotDirectory(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	require.NoError(t, c.fs.WriteFile(filePath, []byte("content"), 0644))
+
+	f, err := c.fs.OpenFile(filePath, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.ReadDir(-1)
+	require.Error(t, err)
+}
+
+func (c *Conformance) TestWriteAtPastEnd(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	f, err := c.fs.Create(filePath)
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("abc"))
+	require.NoError(t, err)
+
+	// the gap between the end and the offset is filled with zeros
+	n, err := f.WriteAt([]byte("xyz"), 6)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	// a negative offset fails with the operation name of *os.File
+	_, err = f.WriteAt([]byte("xyz"), -1)
+	var pathError *iofs.PathError
+	require.ErrorAs(t, err, &pathError)
+	require.Equal(t, "writeat", pathError.Op)
+	require.NoError(t, f.Close())
+
+	content, err := c.fs.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, []byte("abc\x00\x00\x00xyz"), content)
+}
+
+func (c *Conformance) TestSeekPastEnd(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	f, err := c.fs.Create(filePath)
+	require.NoError(t, err)
+
+	_, err = f.WriteString("abc")
+	require.NoError(t, err)
+
+	pos, err := f.Seek(2, io.SeekEnd)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), pos)
+
+	// reading past the end returns io.EOF
+	_, err = f.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF)
+
+	_, err = f.WriteString("d")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := c.fs.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, []byte("abc\x00\x00d"), content)
+}
+
+func (c *Conformance) TestTruncate(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("0123456789"), 0644)
+	require.NoError(t, err)
+
+	f, err := c.fs.OpenFile(filePath, os.O_RDWR, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	// shrinking does not move the offset
+	_, err = f.Seek(8, io.SeekStart)
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(4))
+
+	pos, err := f.Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
+	require.Equal(t, int64(8), pos)
+
+	content, err := c.fs.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "0123", string(content))
+
+	// growing fills with zeros
+	require.NoError(t, f.Truncate(6))
+	content, err = c.fs.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, []byte("0123\x00\x00"), content)
+
+	require.Error(t, f.Truncate(-1))
+}
+
+func (c *Conformance) TestFileNameAndSync(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	f, err := c.fs.Create(filePath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	// the name is the path given to Create, not the base name
+	require.Equal(t, filePath, f.Name())
+
+	_, err = f.WriteString("content")
+	require.NoError(t, err)
+	require.NoError(t, f.Sync())
+}
+
+func (c *Conformance) TestReadFromWriteTo(t *testing.T, folder string, source string, target string, content string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	sourcePath := c.path.Join(folder, source)
+	err = c.fs.WriteFile(sourcePath, []byte(content), 0644)
+	require.NoError(t, err)
+
+	src, err := c.fs.Open(sourcePath)
+	require.NoError(t, err)
+	defer src.Close()
+
+	dst, err := c.fs.Create(c.path.Join(folder, target))
+	require.NoError(t, err)
+	defer dst.Close()
+
+	// copy between two handles of the same file system
+	n, err := dst.ReadFrom(src)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), n)
+
+	_, err = dst.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err = dst.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), n)
+	require.Equal(t, content, buf.String())
+}
+
+func (c *Conformance) TestOpenFileExclusive(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	f, err := c.fs.OpenFile(filePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// a second exclusive create fails, which is what lock files rely on
+	_, err = c.fs.OpenFile(filePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	require.ErrorIs(t, err, iofs.ErrExist)
+
+	var pathError *iofs.PathError
+	require.ErrorAs(t, err, &pathError)
+
+	// without O_CREATE, O_EXCL is ignored
+	f, err = c.fs.OpenFile(filePath, os.O_EXCL|os.O_RDONLY, 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func (c *Conformance) TestOpenFileFailsWhenNotExistsWithoutCreate(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	for _, flag := range []int{os.O_RDONLY, os.O_WRONLY, os.O_RDWR, os.O_WRONLY | os.O_APPEND, os.O_RDWR | os.O_TRUNC} {
+		_, err = c.fs.OpenFile(filePath, flag, 0644)
+		require.ErrorIs(t, err, iofs.ErrNotExist, "flag %d", flag)
+	}
+
+	ok, err := c.fs.Exists(filePath)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func (c *Conformance) TestOpenFileReadOnlyRejectsWrite(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	f, err := c.fs.OpenFile(filePath, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("new"))
+	require.Error(t, err)
+
+	_, err = f.WriteAt([]byte("new"), 0)
+	require.Error(t, err)
+
+	require.Error(t, f.Truncate(0))
+
+	content, err := c.fs.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+}
+
+func (c *Conformance) TestOpenFileWriteOnlyRejectsRead(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	f, err := c.fs.OpenFile(filePath, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	buf := make([]byte, 3)
+	_, err = f.Read(buf)
+	require.Error(t, err)
+
+	_, err = f.ReadAt(buf, 0)
+	require.Error(t, err)
+
+	// the handle can still write
+	_, err = f.Write([]byte("new"))
+	require.NoError(t, err)
+}
+
+func (c *Conformance) TestOpenFileAppendIgnoresSeek(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	f, err := c.fs.OpenFile(filePath, os.O_RDWR|os.O_APPEND, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte(" appended"))
+	require.NoError(t, err)
+
+	// WriteAt is not allowed with O_APPEND
+	_, err = f.WriteAt([]byte("x"), 0)
+	require.Error(t, err)
+
+	content, err := c.fs.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "content appended", string(content))
+}
+
+func (c *Conformance) TestOpenFileSync(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	f, err := c.fs.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_SYNC, 0644)
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("content"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := c.fs.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+}
+
+func (c *Conformance) TestUseAfterClose(t *testing.T, folder string, file string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	f, err := c.fs.OpenFile(filePath, os.O_CREATE|os.O_RDWR, 0644)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	buf := make([]byte, 1)
+	_, err = f.Read(buf)
+	require.ErrorIs(t, err, iofs.ErrClosed)
+
+	_, err = f.ReadAt(buf, 0)
+	require.ErrorIs(t, err, iofs.ErrClosed)
+
+	_, err = f.Write(buf)
+	require.ErrorIs(t, err, iofs.ErrClosed)
+
+	_, err = f.WriteAt(buf, 0)
+	require.ErrorIs(t, err, iofs.ErrClosed)
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.ErrorIs(t, err, iofs.ErrClosed)
+
+	_, err = f.Stat()
+	require.ErrorIs(t, err, iofs.ErrClosed)
+
+	require.ErrorIs(t, f.Truncate(0), iofs.ErrClosed)
+	require.ErrorIs(t, f.Sync(), iofs.ErrClosed)
+	require.ErrorIs(t, f.Close(), iofs.ErrClosed)
+}
+
+func (c *Conformance) TestRemoveOpenFile(t *testing.T, folder string, file string, content string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte(content), 0644)
+	require.NoError(t, err)
+
+	f, err := c.fs.Open(filePath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	err = c.fs.Remove(filePath)
+
+	// windows refuses to remove a file that is in use
+	if c.path.OS.Platform().IsWindows() {
+		require.Error(t, err)
+		var pathError *iofs.PathError
+		require.ErrorAs(t, err, &pathError)
+
+		ok, err := c.fs.Exists(filePath)
+		require.NoError(t, err)
+		require.True(t, ok)
+		return
+	}
+
+	// posix removes the name while the open handle keeps the data
+	require.NoError(t, err)
+	ok, err := c.fs.Exists(filePath)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	read, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, content, string(read))
+}
+
+func (c *Conformance) TestRenameOpenFile(t *testing.T, folder string, source string, target string, content string) {
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	sourcePath := c.path.Join(folder, source)
+	targetPath := c.path.Join(folder, target)
+	err = c.fs.WriteFile(sourcePath, []byte(content), 0644)
+	require.NoError(t, err)
+
+	f, err := c.fs.Open(sourcePath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	err = c.fs.Rename(sourcePath, targetPath)
+
+	// windows refuses to move a file that is in use
+	if c.path.OS.Platform().IsWindows() {
+		require.Error(t, err)
+		var linkError *os.LinkError
+		require.ErrorAs(t, err, &linkError)
+
+		ok, err := c.fs.Exists(sourcePath)
+		require.NoError(t, err)
+		require.True(t, ok)
+		return
+	}
+
+	// posix moves the name while the open handle keeps reading the same file
+	require.NoError(t, err)
+	read, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, content, string(read))
+
+	read, err = c.fs.ReadFile(targetPath)
+	require.NoError(t, err)
+	require.Equal(t, content, string(read))
+}
+
+// TestCasePreserving verifies a case-insensitive file system finds entries by any casing
+// while listing them with the casing they were created with
+func (c *Conformance) TestCasePreserving(t *testing.T, folder string, directory string, file string) {
+	err := c.fs.MkdirAll(c.path.Join(folder, directory), 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, directory, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	// lookups ignore case
+	upper := c.path.Join(folder, strings.ToUpper(directory), strings.ToUpper(file))
+	content, err := c.fs.ReadFile(upper)
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+
+	// writing through another casing keeps the original name
+	err = c.fs.WriteFile(c.path.Join(folder, directory, strings.ToLower(file)), []byte("changed"), 0644)
+	require.NoError(t, err)
+
+	entries, err := c.fs.ReadDir(folder)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, directory, entries[0].Name())
+
+	entries, err = c.fs.ReadDir(c.path.Join(folder, strings.ToLower(directory)))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, file, entries[0].Name())
+
+	// a rename that only changes the case renames the entry
+	renamed := c.path.Join(folder, directory, strings.ToUpper(file))
+	err = c.fs.Rename(filePath, renamed)
+	require.NoError(t, err)
+
+	entries, err = c.fs.ReadDir(c.path.Join(folder, directory))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, strings.ToUpper(file), entries[0].Name())
+}
+
+// TestNormalizationInsensitive verifies a file system that ignores unicode normalization finds entries by any
+// canonically equivalent name while listing them in the form they were created with
+func (c *Conformance) TestNormalizationInsensitive(t *testing.T, folder string) {
+	// e acute as a single code point (NFC) and as e followed by a combining acute accent (NFD)
+	nfc := "caf\u00e9"
+	nfd := "cafe\u0301"
+
+	err := c.fs.MkdirAll(c.path.Join(folder, nfd), 0777)
+	require.NoError(t, err)
+
+	err = c.fs.WriteFile(c.path.Join(folder, nfd, "menu.txt"), []byte("menu"), 0644)
+	require.NoError(t, err)
+
+	content, err := c.fs.ReadFile(c.path.Join(folder, nfc, "menu.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "menu", string(content))
+
+	// creating the other form refers to the same directory
+	err = c.fs.MkdirAll(c.path.Join(folder, nfc), 0777)
+	require.NoError(t, err)
+
+	entries, err := c.fs.ReadDir(folder)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, nfd, entries[0].Name())
+}
+
+func (c *Conformance) TestLinkSharesData(t *testing.T, folder string, file string, link string) {
+	links := c.linkFS(t)
+	err := c.fs.MkdirAll(folder, 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	linkPath := c.path.Join(folder, link)
+	err = c.fs.WriteFile(filePath, []byte("original"), 0644)
+	require.NoError(t, err)
+
+	err = links.Link(filePath, linkPath)
+	require.NoError(t, err)
+
+	// a write through one name is visible through the other
+	err = c.fs.WriteFile(linkPath, []byte("changed"), 0644)
+	require.NoError(t, err)
+
+	content, err := c.fs.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "changed", string(content))
+
+	f, err := c.fs.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(" again"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	content, err = c.fs.ReadFile(linkPath)
+	require.NoError(t, err)
+	require.Equal(t, "changed again", string(content))
+
+	// removing one name keeps the data for the other
+	err = c.fs.Remove(filePath)
+	require.NoError(t, err)
+
+	content, err = c.fs.ReadFile(linkPath)
+	require.NoError(t, err)
+	require.Equal(t, "changed again", string(content))
+}
+
+func (c *Conformance) TestLinkFails(t *testing.T, folder string, file string, dir string) {
+	links := c.linkFS(t)
+	err := c.fs.MkdirAll(c.path.Join(folder, dir), 0777)
+	require.NoError(t, err)
+
+	filePath := c.path.Join(folder, file)
+	err = c.fs.WriteFile(filePath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	var linkError *os.LinkError
+
+	// the new name must not exist
+	err = links.Link(filePath, filePath)
+	require.ErrorIs(t, err, iofs.ErrExist)
+	require.ErrorAs(t, err, &linkError)
+
+	// the old name must exist
+	err = links.Link(c.path.Join(folder, "missing.txt"), c.path.Join(folder, "link.txt"))
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+	require.ErrorAs(t, err, &linkError)
+
+	// directories can't be hard linked
+	err = links.Link(c.path.Join(folder, dir), c.path.Join(folder, "dirlink"))
+	require.Error(t, err)
+	require.ErrorAs(t, err, &linkError)
+}
@@ -0,0 +1,555 @@
+package fstesting
+
+import (
+	"errors"
+	"io"
+	iofs "io/fs"
+	"math/rand"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+	"github.com/patrickhuber/go-xplat/fs"
+)
+
+// Rule selects calls of a Faulty file system and the fault injected into them. Empty fields match every call.
+type Rule struct {
+	// Op is the name of the fs.FS or fs.File method, for example "OpenFile", "Rename" or "Write"
+	Op string
+	// Path is a pattern in the path.Match syntax matched one segment at a time against the name. File
+	// methods match the name the file was opened with and Rename, Link and Symlink match either name.
+	Path string
+	// Nth fires the rule only on the nth matching call, starting at 1
+	Nth int
+	// Probability fires the rule on a matching call with the given chance using the seeded random source
+	Probability float64
+
+	// Err is returned from the call. Errors that are not already an *fs.PathError or *os.LinkError are wrapped in one.
+	Err error
+	// ShortWrite limits a write to the number of bytes. The write then fails with Err or io.ErrShortWrite.
+	ShortWrite int
+	// Delay is waited before the call continues or fails
+	Delay time.Duration
+}
+
+// rule is an injected rule and the number of calls it has matched
+type rule struct {
+	Rule
+	calls int
+}
+
+// Faulty wraps a file system and injects errors, short writes and delays into the calls selected by rules.
+// It is used to test how code behaves when the disk is full, a device fails or a write is cut short.
+type Faulty struct {
+	fsys   fs.FS
+	path   *filepath.Processor
+	mutex  sync.Mutex
+	rules  []*rule
+	random *rand.Rand
+}
+
+type FaultyOption func(*Faulty)
+
+// WithSeed seeds the random source used by rules with a Probability
+func WithSeed(seed int64) FaultyOption {
+	return func(f *Faulty) {
+		f.random = rand.New(rand.NewSource(seed))
+	}
+}
+
+// NewFaulty creates a file system that passes calls to fsys until a rule fires. Patterns are matched with the
+// processor, a nil processor uses the processor of the current platform.
+func NewFaulty(fsys fs.FS, path *filepath.Processor, options ...FaultyOption) *Faulty {
+	f := &Faulty{
+		fsys: fsys,
+		path: path,
+	}
+	for _, option := range options {
+		option(f)
+	}
+	if f.random == nil {
+		f.random = rand.New(rand.NewSource(1))
+	}
+	if f.path == nil {
+		f.path = filepath.NewProcessor()
+	}
+	return f
+}
+
+// Inject adds the rule. Rules are checked in the order they were injected and the first one that fires is used.
+func (f *Faulty) Inject(r Rule) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.rules = append(f.rules, &rule{Rule: r})
+}
+
+// Reset removes all rules
+func (f *Faulty) Reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.rules = nil
+}
+
+// OpenHandles implements fs.HandleFS when the wrapped file system tracks its handles
+func (f *Faulty) OpenHandles() []fs.Handle {
+	if handles, ok := f.fsys.(fs.HandleFS); ok {
+		return handles.OpenHandles()
+	}
+	return nil
+}
+
+// fault returns the rule that fires for the call, or nil, after waiting for its delay. Every matching rule
+// counts the call, even when an earlier rule fires, so Nth always refers to the calls the rule matched.
+func (f *Faulty) fault(op string, names ...string) *Rule {
+	f.mutex.Lock()
+	var fired *Rule
+	for _, r := range f.rules {
+		if !f.matches(r, op, names) {
+			continue
+		}
+		r.calls++
+		if fired != nil {
+			continue
+		}
+		if r.Nth > 0 && r.calls != r.Nth {
+			continue
+		}
+		if r.Probability > 0 && f.random.Float64() >= r.Probability {
+			continue
+		}
+		match := r.Rule
+		fired = &match
+	}
+	f.mutex.Unlock()
+
+	if fired != nil && fired.Delay > 0 {
+		time.Sleep(fired.Delay)
+	}
+	return fired
+}
+
+// matches returns true if the rule selects the operation on any of the names
+func (f *Faulty) matches(r *rule, op string, names []string) bool {
+	if r.Op != "" && r.Op != op {
+		return false
+	}
+	if r.Path == "" {
+		return true
+	}
+	for _, name := range names {
+		if f.match(r.Path, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// match matches the pattern against the name one segment at a time
+func (f *Faulty) match(pattern string, name string) bool {
+	p, err := f.path.Parser.Parse(pattern)
+	if err != nil {
+		return false
+	}
+	n, err := f.path.Parser.Parse(name)
+	if err != nil {
+		return false
+	}
+	if p.IsAbs() != n.IsAbs() || len(p.Segments) != len(n.Segments) {
+		return false
+	}
+	if !f.path.Comparison.Equal(p.VolumeName(f.path.Separator), n.VolumeName(f.path.Separator)) {
+		return false
+	}
+	for i := range p.Segments {
+		if ok, _ := path.Match(p.Segments[i], n.Segments[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// err returns the error of the rule that fires for the call
+func (f *Faulty) err(op string, name string) error {
+	r := f.fault(op, name)
+	if r == nil || r.Err == nil {
+		return nil
+	}
+	return pathError(op, name, r.Err)
+}
+
+// linkErr returns the error of the rule that fires for a call with two names
+func (f *Faulty) linkErr(op string, oldname string, newname string) error {
+	r := f.fault(op, oldname, newname)
+	if r == nil || r.Err == nil {
+		return nil
+	}
+	if isWrapped(r.Err) {
+		return r.Err
+	}
+	return &os.LinkError{Op: strings.ToLower(op), Old: oldname, New: newname, Err: r.Err}
+}
+
+// write writes the bytes and applies the short write and error of the rule
+func (f *Faulty) write(r *Rule, op string, name string, b []byte, write func([]byte) (int, error)) (int, error) {
+	if r == nil {
+		return write(b)
+	}
+	if r.ShortWrite > 0 && r.ShortWrite < len(b) {
+		n, err := write(b[:r.ShortWrite])
+		if err != nil {
+			return n, err
+		}
+		failure := r.Err
+		if failure == nil {
+			failure = io.ErrShortWrite
+		}
+		return n, pathError(op, name, failure)
+	}
+	if r.Err != nil {
+		return 0, pathError(op, name, r.Err)
+	}
+	return write(b)
+}
+
+// pathError wraps the error in a *fs.PathError named after the method
+func pathError(op string, name string, err error) error {
+	if isWrapped(err) {
+		return err
+	}
+	return &iofs.PathError{Op: strings.ToLower(op), Path: name, Err: err}
+}
+
+// isWrapped returns true if the error already carries a name
+func isWrapped(err error) bool {
+	var pathError *iofs.PathError
+	var linkError *os.LinkError
+	return errors.As(err, &pathError) || errors.As(err, &linkError)
+}
+
+// Open implements fs.FS
+func (f *Faulty) Open(name string) (iofs.File, error) {
+	if err := f.err("Open", name); err != nil {
+		return nil, err
+	}
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if handle, ok := file.(fs.File); ok {
+		return &faultyFile{File: handle, faulty: f}, nil
+	}
+	return file, nil
+}
+
+// OpenFile implements fs.OpenFileFS
+func (f *Faulty) OpenFile(name string, flag int, perm iofs.FileMode) (fs.File, error) {
+	if err := f.err("OpenFile", name); err != nil {
+		return nil, err
+	}
+	file, err := f.fsys.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &faultyFile{File: file, faulty: f}, nil
+}
+
+// Create implements fs.CreateFS
+func (f *Faulty) Create(name string) (fs.File, error) {
+	if err := f.err("Create", name); err != nil {
+		return nil, err
+	}
+	file, err := f.fsys.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &faultyFile{File: file, faulty: f}, nil
+}
+
+// WriteFile implements fs.WriteFileFS. A short write stores the first bytes of the data.
+func (f *Faulty) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	op := "WriteFile"
+	_, err := f.write(f.fault(op, name), op, name, data, func(b []byte) (int, error) {
+		return len(b), f.fsys.WriteFile(name, b, perm)
+	})
+	return err
+}
+
+// ReadFile implements iofs.ReadFileFS
+func (f *Faulty) ReadFile(name string) ([]byte, error) {
+	if err := f.err("ReadFile", name); err != nil {
+		return nil, err
+	}
+	return f.fsys.ReadFile(name)
+}
+
+// ReadDir implements iofs.ReadDirFS
+func (f *Faulty) ReadDir(name string) ([]iofs.DirEntry, error) {
+	if err := f.err("ReadDir", name); err != nil {
+		return nil, err
+	}
+	return f.fsys.ReadDir(name)
+}
+
+// Stat implements iofs.StatFS
+func (f *Faulty) Stat(name string) (iofs.FileInfo, error) {
+	if err := f.err("Stat", name); err != nil {
+		return nil, err
+	}
+	return f.fsys.Stat(name)
+}
+
+// Lstat implements fs.SymlinkFS
+func (f *Faulty) Lstat(name string) (iofs.FileInfo, error) {
+	if err := f.err("Lstat", name); err != nil {
+		return nil, err
+	}
+	return fs.Lstat(f.fsys, name)
+}
+
+// Readlink implements fs.SymlinkFS
+func (f *Faulty) Readlink(name string) (string, error) {
+	if err := f.err("Readlink", name); err != nil {
+		return "", err
+	}
+	return fs.Readlink(f.fsys, name)
+}
+
+// Exists implements fs.ExistsFS
+func (f *Faulty) Exists(name string) (bool, error) {
+	if err := f.err("Exists", name); err != nil {
+		return false, err
+	}
+	return f.fsys.Exists(name)
+}
+
+// Glob implements iofs.GlobFS. Rules match the pattern as the name.
+func (f *Faulty) Glob(pattern string) ([]string, error) {
+	if err := f.err("Glob", pattern); err != nil {
+		return nil, err
+	}
+	return f.fsys.Glob(pattern)
+}
+
+// Sub implements iofs.SubFS. Files opened from the sub file system are still subject to the rules.
+func (f *Faulty) Sub(dir string) (iofs.FS, error) {
+	if err := f.err("Sub", dir); err != nil {
+		return nil, err
+	}
+	return &faultySub{faulty: f, dir: dir}, nil
+}
+
+// Mkdir implements fs.MakeDirFS
+func (f *Faulty) Mkdir(name string, perm iofs.FileMode) error {
+	if err := f.err("Mkdir", name); err != nil {
+		return err
+	}
+	return f.fsys.Mkdir(name, perm)
+}
+
+// MkdirAll implements fs.MakeDirFS
+func (f *Faulty) MkdirAll(name string, perm iofs.FileMode) error {
+	if err := f.err("MkdirAll", name); err != nil {
+		return err
+	}
+	return f.fsys.MkdirAll(name, perm)
+}
+
+// Remove implements fs.RemoveFS
+func (f *Faulty) Remove(name string) error {
+	if err := f.err("Remove", name); err != nil {
+		return err
+	}
+	return f.fsys.Remove(name)
+}
+
+// RemoveAll implements fs.RemoveFS
+func (f *Faulty) RemoveAll(name string) error {
+	if err := f.err("RemoveAll", name); err != nil {
+		return err
+	}
+	return f.fsys.RemoveAll(name)
+}
+
+// Rename implements fs.RenameFS
+func (f *Faulty) Rename(oldpath string, newpath string) error {
+	if err := f.linkErr("Rename", oldpath, newpath); err != nil {
+		return err
+	}
+	return f.fsys.Rename(oldpath, newpath)
+}
+
+// Symlink implements fs.SymlinkFS
+func (f *Faulty) Symlink(oldname string, newname string) error {
+	if err := f.linkErr("Symlink", oldname, newname); err != nil {
+		return err
+	}
+	return fs.Symlink(f.fsys, oldname, newname)
+}
+
+// Link implements fs.LinkFS
+func (f *Faulty) Link(oldname string, newname string) error {
+	if err := f.linkErr("Link", oldname, newname); err != nil {
+		return err
+	}
+	return fs.Link(f.fsys, oldname, newname)
+}
+
+// Chmod implements fs.ChangeFS
+func (f *Faulty) Chmod(name string, mode iofs.FileMode) error {
+	if err := f.err("Chmod", name); err != nil {
+		return err
+	}
+	return fs.Chmod(f.fsys, name, mode)
+}
+
+// Chtimes implements fs.ChangeFS
+func (f *Faulty) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := f.err("Chtimes", name); err != nil {
+		return err
+	}
+	return fs.Chtimes(f.fsys, name, atime, mtime)
+}
+
+// Chown implements fs.ChangeFS
+func (f *Faulty) Chown(name string, uid, gid int) error {
+	if err := f.err("Chown", name); err != nil {
+		return err
+	}
+	return fs.Chown(f.fsys, name, uid, gid)
+}
+
+// Lchown implements fs.ChangeFS
+func (f *Faulty) Lchown(name string, uid, gid int) error {
+	if err := f.err("Lchown", name); err != nil {
+		return err
+	}
+	return fs.Lchown(f.fsys, name, uid, gid)
+}
+
+// faultySub is the sub file system of a Faulty file system
+type faultySub struct {
+	faulty *Faulty
+	dir    string
+}
+
+func (s *faultySub) Open(name string) (iofs.File, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrInvalid}
+	}
+	file, err := s.faulty.Open(s.faulty.path.Join(s.dir, name))
+	if err != nil {
+		var pathError *iofs.PathError
+		if errors.As(err, &pathError) {
+			pathError.Path = name
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// faultyFile is a handle whose methods are subject to the rules of the file system that opened it
+type faultyFile struct {
+	fs.File
+	faulty *Faulty
+}
+
+func (h *faultyFile) Stat() (iofs.FileInfo, error) {
+	if err := h.faulty.err("Stat", h.Name()); err != nil {
+		return nil, err
+	}
+	return h.File.Stat()
+}
+
+func (h *faultyFile) Read(b []byte) (int, error) {
+	if err := h.faulty.err("Read", h.Name()); err != nil {
+		return 0, err
+	}
+	return h.File.Read(b)
+}
+
+func (h *faultyFile) ReadAt(b []byte, offset int64) (int, error) {
+	if err := h.faulty.err("ReadAt", h.Name()); err != nil {
+		return 0, err
+	}
+	return h.File.ReadAt(b, offset)
+}
+
+func (h *faultyFile) Write(b []byte) (int, error) {
+	op := "Write"
+	return h.faulty.write(h.faulty.fault(op, h.Name()), op, h.Name(), b, h.File.Write)
+}
+
+func (h *faultyFile) WriteAt(b []byte, offset int64) (int, error) {
+	op := "WriteAt"
+	return h.faulty.write(h.faulty.fault(op, h.Name()), op, h.Name(), b, func(b []byte) (int, error) {
+		return h.File.WriteAt(b, offset)
+	})
+}
+
+func (h *faultyFile) WriteString(s string) (int, error) {
+	op := "WriteString"
+	return h.faulty.write(h.faulty.fault(op, h.Name()), op, h.Name(), []byte(s), h.File.Write)
+}
+
+func (h *faultyFile) Seek(offset int64, whence int) (int64, error) {
+	if err := h.faulty.err("Seek", h.Name()); err != nil {
+		return 0, err
+	}
+	return h.File.Seek(offset, whence)
+}
+
+// ReadFrom copies through Write so the rules of Write also apply
+func (h *faultyFile) ReadFrom(r io.Reader) (int64, error) {
+	if err := h.faulty.err("ReadFrom", h.Name()); err != nil {
+		return 0, err
+	}
+	return io.Copy(struct{ io.Writer }{h}, r)
+}
+
+// WriteTo copies through Read so the rules of Read also apply
+func (h *faultyFile) WriteTo(w io.Writer) (int64, error) {
+	if err := h.faulty.err("WriteTo", h.Name()); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, struct{ io.Reader }{h})
+}
+
+func (h *faultyFile) ReadDir(n int) ([]iofs.DirEntry, error) {
+	if err := h.faulty.err("ReadDir", h.Name()); err != nil {
+		return nil, err
+	}
+	return h.File.ReadDir(n)
+}
+
+func (h *faultyFile) Readdirnames(n int) ([]string, error) {
+	if err := h.faulty.err("Readdirnames", h.Name()); err != nil {
+		return nil, err
+	}
+	return h.File.Readdirnames(n)
+}
+
+func (h *faultyFile) Truncate(size int64) error {
+	if err := h.faulty.err("Truncate", h.Name()); err != nil {
+		return err
+	}
+	return h.File.Truncate(size)
+}
+
+func (h *faultyFile) Sync() error {
+	if err := h.faulty.err("Sync", h.Name()); err != nil {
+		return err
+	}
+	return h.File.Sync()
+}
+
+// Close always closes the wrapped handle so a failed close does not leak it
+func (h *faultyFile) Close() error {
+	injected := h.faulty.err("Close", h.Name())
+	if err := h.File.Close(); err != nil {
+		return err
+	}
+	return injected
+}
@@ -0,0 +1,162 @@
+package fstesting_test
+
+import (
+	"errors"
+	"io"
+	iofs "io/fs"
+	stdos "os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/fs/fstesting"
+	"github.com/patrickhuber/go-xplat/os"
+	"github.com/patrickhuber/go-xplat/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func setupFaulty(t *testing.T, options ...fstesting.FaultyOption) (*fstesting.Faulty, fs.FS, *filepath.Processor) {
+	processor := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(platform.Linux)))
+	memory := fs.NewMemory(fs.WithProcessor(processor))
+	require.NoError(t, memory.MkdirAll("/gran/parent", 0777))
+	return fstesting.NewFaulty(memory, processor, options...), memory, processor
+}
+
+func TestFaultyConformance(t *testing.T) {
+	faulty, _, processor := setupFaulty(t)
+	fstesting.NewConformance(faulty, processor).Run(t, "/conformance")
+}
+
+func TestFaultyReturnsError(t *testing.T) {
+	faulty, _, _ := setupFaulty(t)
+	faulty.Inject(fstesting.Rule{Op: "Mkdir", Path: "/gran/*", Err: syscall.EACCES})
+
+	err := faulty.Mkdir("/gran/child", 0777)
+	require.ErrorIs(t, err, syscall.EACCES)
+	var pathError *iofs.PathError
+	require.ErrorAs(t, err, &pathError)
+	require.Equal(t, "mkdir", pathError.Op)
+	require.Equal(t, "/gran/child", pathError.Path)
+
+	// other paths and operations are passed through
+	require.NoError(t, faulty.Mkdir("/gran/parent/child", 0777))
+	require.NoError(t, faulty.WriteFile("/gran/file.txt", []byte("content"), 0644))
+}
+
+func TestFaultyRenameMatchesEitherName(t *testing.T) {
+	faulty, _, _ := setupFaulty(t)
+	require.NoError(t, faulty.WriteFile("/gran/file.txt", []byte("content"), 0644))
+	faulty.Inject(fstesting.Rule{Op: "Rename", Path: "/gran/parent/*", Err: syscall.EXDEV})
+
+	err := faulty.Rename("/gran/file.txt", "/gran/parent/file.txt")
+	require.ErrorIs(t, err, syscall.EXDEV)
+	var linkError *stdos.LinkError
+	require.ErrorAs(t, err, &linkError)
+}
+
+func TestFaultyNthCall(t *testing.T) {
+	faulty, _, _ := setupFaulty(t)
+	faulty.Inject(fstesting.Rule{Op: "WriteFile", Nth: 2, Err: syscall.EIO})
+
+	require.NoError(t, faulty.WriteFile("/gran/one.txt", []byte("one"), 0644))
+	require.ErrorIs(t, faulty.WriteFile("/gran/two.txt", []byte("two"), 0644), syscall.EIO)
+	require.NoError(t, faulty.WriteFile("/gran/three.txt", []byte("three"), 0644))
+
+	ok, err := faulty.Exists("/gran/two.txt")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestFaultyNthCountsEveryMatchingRule(t *testing.T) {
+	faulty, _, _ := setupFaulty(t)
+	faulty.Inject(fstesting.Rule{Op: "WriteFile", Nth: 1, Err: syscall.EIO})
+	faulty.Inject(fstesting.Rule{Op: "WriteFile", Nth: 2, Err: syscall.ENOSPC})
+
+	require.ErrorIs(t, faulty.WriteFile("/gran/one.txt", []byte("one"), 0644), syscall.EIO)
+	require.ErrorIs(t, faulty.WriteFile("/gran/two.txt", []byte("two"), 0644), syscall.ENOSPC)
+	require.NoError(t, faulty.WriteFile("/gran/three.txt", []byte("three"), 0644))
+}
+
+func TestFaultyDefaultProcessor(t *testing.T) {
+	processor := filepath.NewProcessor()
+	name, err := processor.Abs("child")
+	require.NoError(t, err)
+
+	// without a processor patterns are matched with the processor of the current platform
+	faulty := fstesting.NewFaulty(fs.NewMemory(), nil)
+	faulty.Inject(fstesting.Rule{Op: "Mkdir", Path: name, Err: syscall.EACCES})
+	require.ErrorIs(t, faulty.Mkdir(name, 0777), syscall.EACCES)
+}
+
+func TestFaultyProbabilityIsSeeded(t *testing.T) {
+	run := func() []bool {
+		faulty, _, _ := setupFaulty(t, fstesting.WithSeed(42))
+		faulty.Inject(fstesting.Rule{Op: "Stat", Probability: 0.5, Err: syscall.EIO})
+		var failures []bool
+		for i := 0; i < 32; i++ {
+			_, err := faulty.Stat("/gran")
+			failures = append(failures, err != nil)
+		}
+		return failures
+	}
+	first := run()
+	require.Equal(t, first, run())
+	require.Contains(t, first, true)
+	require.Contains(t, first, false)
+}
+
+func TestFaultyShortWrite(t *testing.T) {
+	faulty, memory, _ := setupFaulty(t)
+	faulty.Inject(fstesting.Rule{Op: "Write", Path: "/gran/*.txt", ShortWrite: 3, Err: syscall.ENOSPC})
+
+	f, err := faulty.Create("/gran/file.txt")
+	require.NoError(t, err)
+	n, err := f.Write([]byte("content"))
+	require.Equal(t, 3, n)
+	require.ErrorIs(t, err, syscall.ENOSPC)
+	require.NoError(t, f.Close())
+
+	content, err := memory.ReadFile("/gran/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "con", string(content))
+
+	faulty.Reset()
+	faulty.Inject(fstesting.Rule{Op: "WriteFile", ShortWrite: 2})
+	err = faulty.WriteFile("/gran/file.txt", []byte("content"), 0644)
+	require.ErrorIs(t, err, io.ErrShortWrite)
+
+	content, err = memory.ReadFile("/gran/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "co", string(content))
+}
+
+func TestFaultyHandleMethods(t *testing.T) {
+	faulty, memory, _ := setupFaulty(t)
+	require.NoError(t, faulty.WriteFile("/gran/file.txt", []byte("content"), 0644))
+	failure := errors.New("injected")
+	faulty.Inject(fstesting.Rule{Op: "Read", Err: failure})
+	faulty.Inject(fstesting.Rule{Op: "Close", Err: failure})
+
+	f, err := faulty.Open("/gran/file.txt")
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(f)
+	require.ErrorIs(t, err, failure)
+
+	// the handle is closed even when close fails
+	require.ErrorIs(t, f.Close(), failure)
+	fstesting.RequireNoOpenHandles(t, memory)
+}
+
+func TestFaultyDelay(t *testing.T) {
+	faulty, _, _ := setupFaulty(t)
+	delay := 20 * time.Millisecond
+	faulty.Inject(fstesting.Rule{Op: "ReadDir", Delay: delay})
+
+	start := time.Now()
+	_, err := faulty.ReadDir("/gran")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), delay)
+}
@@ -0,0 +1,32 @@
+package fstesting
+
+import (
+	"fmt"
+	iofs "io/fs"
+	"strings"
+	"testing"
+
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// RequireNoOpenHandles fails the test with the stack of every handle that is still open. File systems that
+// do not implement fs.HandleFS are not checked.
+func RequireNoOpenHandles(t testing.TB, fsys iofs.FS) {
+	t.Helper()
+
+	tracker, ok := fsys.(fs.HandleFS)
+	if !ok {
+		return
+	}
+	open := tracker.OpenHandles()
+	if len(open) == 0 {
+		return
+	}
+
+	var sb strings.Builder
+	for _, handle := range open {
+		fmt.Fprintf(&sb, "%s opened at:\n%s\n", handle.Name, handle.Stack)
+	}
+	require.Fail(t, fmt.Sprintf("%d file handles were not closed", len(open)), sb.String())
+}
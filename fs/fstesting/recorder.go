@@ -0,0 +1,554 @@
+package fstesting
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/clock"
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// Entry is a call recorded by a Recorder
+type Entry struct {
+	// Op is the name of the fs.FS or fs.File method, for example "OpenFile", "Rename" or "Write"
+	Op string
+	// Path is the name passed to the method. File methods record the name the file was opened with.
+	Path string
+	// NewPath is the second name of Rename, Link and Symlink
+	NewPath string
+	// Flag is the flag a file was opened with
+	Flag int
+	// Bytes is the number of bytes read or written
+	Bytes int64
+	// Err is the error returned from the call
+	Err error
+	// Duration is how long the call took
+	Duration time.Duration
+}
+
+// String formats the entry as one line of a trace
+func (e Entry) String() string {
+	var sb strings.Builder
+	sb.WriteString(e.Op)
+	sb.WriteString(" ")
+	sb.WriteString(e.Path)
+	if e.NewPath != "" {
+		fmt.Fprintf(&sb, " -> %s", e.NewPath)
+	}
+	if e.Flag != 0 || e.Op == "OpenFile" || e.Op == "Open" {
+		fmt.Fprintf(&sb, " flag=%s", formatFlag(e.Flag))
+	}
+	if e.Bytes != 0 {
+		fmt.Fprintf(&sb, " bytes=%d", e.Bytes)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&sb, " err=%q", e.Err.Error())
+	}
+	fmt.Fprintf(&sb, " (%s)", e.Duration)
+	return sb.String()
+}
+
+// formatFlag formats the flag using the names of the os package constants
+func formatFlag(flag int) string {
+	var names []string
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_WRONLY:
+		names = append(names, "O_WRONLY")
+	case os.O_RDWR:
+		names = append(names, "O_RDWR")
+	default:
+		names = append(names, "O_RDONLY")
+	}
+	others := []struct {
+		flag int
+		name string
+	}{
+		{os.O_APPEND, "O_APPEND"},
+		{os.O_CREATE, "O_CREATE"},
+		{os.O_EXCL, "O_EXCL"},
+		{os.O_SYNC, "O_SYNC"},
+		{os.O_TRUNC, "O_TRUNC"},
+	}
+	for _, other := range others {
+		if flag&other.flag != 0 {
+			names = append(names, other.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Recorder wraps a file system and records every call of the file system and the files it opens
+// to a journal. Each call is also written as a line to the writer if one is set.
+type Recorder struct {
+	fsys    fs.FS
+	clock   clock.Clock
+	writer  io.Writer
+	mutex   sync.Mutex
+	entries []Entry
+}
+
+type RecorderOption func(*Recorder)
+
+// WithWriter writes every recorded call as a line to the writer, for example console.Console.Error()
+func WithWriter(writer io.Writer) RecorderOption {
+	return func(r *Recorder) {
+		r.writer = writer
+	}
+}
+
+// WithClock sets the clock used to measure the duration of calls
+func WithClock(c clock.Clock) RecorderOption {
+	return func(r *Recorder) {
+		r.clock = c
+	}
+}
+
+// NewRecorder creates a file system that records the calls made to fsys
+func NewRecorder(fsys fs.FS, options ...RecorderOption) *Recorder {
+	r := &Recorder{
+		fsys: fsys,
+	}
+	for _, option := range options {
+		option(r)
+	}
+	if r.clock == nil {
+		r.clock = clock.New()
+	}
+	return r
+}
+
+// Entries returns the recorded calls in the order they completed
+func (r *Recorder) Entries() []Entry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
+
+// Reset clears the journal
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entries = nil
+}
+
+// OpenHandles implements fs.HandleFS when the wrapped file system tracks its handles
+func (r *Recorder) OpenHandles() []fs.Handle {
+	if handles, ok := r.fsys.(fs.HandleFS); ok {
+		return handles.OpenHandles()
+	}
+	return nil
+}
+
+// record adds the entry to the journal and writes it to the writer
+func (r *Recorder) record(entry Entry, start time.Time) {
+	entry.Duration = r.clock.Now().Sub(start)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entries = append(r.entries, entry)
+	if r.writer != nil {
+		fmt.Fprintln(r.writer, entry)
+	}
+}
+
+// Open implements fs.FS
+func (r *Recorder) Open(name string) (iofs.File, error) {
+	start := r.clock.Now()
+	file, err := r.fsys.Open(name)
+	r.record(Entry{Op: "Open", Path: name, Flag: os.O_RDONLY, Err: err}, start)
+	if err != nil {
+		return nil, err
+	}
+	if handle, ok := file.(fs.File); ok {
+		return &recordedFile{File: handle, recorder: r}, nil
+	}
+	return file, nil
+}
+
+// OpenFile implements fs.OpenFileFS
+func (r *Recorder) OpenFile(name string, flag int, perm iofs.FileMode) (fs.File, error) {
+	start := r.clock.Now()
+	file, err := r.fsys.OpenFile(name, flag, perm)
+	r.record(Entry{Op: "OpenFile", Path: name, Flag: flag, Err: err}, start)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedFile{File: file, recorder: r}, nil
+}
+
+// Create implements fs.CreateFS
+func (r *Recorder) Create(name string) (fs.File, error) {
+	start := r.clock.Now()
+	file, err := r.fsys.Create(name)
+	r.record(Entry{Op: "Create", Path: name, Flag: os.O_RDWR | os.O_CREATE | os.O_TRUNC, Err: err}, start)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedFile{File: file, recorder: r}, nil
+}
+
+// WriteFile implements fs.WriteFileFS
+func (r *Recorder) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	start := r.clock.Now()
+	err := r.fsys.WriteFile(name, data, perm)
+	entry := Entry{Op: "WriteFile", Path: name, Err: err}
+	if err == nil {
+		entry.Bytes = int64(len(data))
+	}
+	r.record(entry, start)
+	return err
+}
+
+// ReadFile implements iofs.ReadFileFS
+func (r *Recorder) ReadFile(name string) ([]byte, error) {
+	start := r.clock.Now()
+	data, err := r.fsys.ReadFile(name)
+	r.record(Entry{Op: "ReadFile", Path: name, Bytes: int64(len(data)), Err: err}, start)
+	return data, err
+}
+
+// ReadDir implements iofs.ReadDirFS
+func (r *Recorder) ReadDir(name string) ([]iofs.DirEntry, error) {
+	start := r.clock.Now()
+	entries, err := r.fsys.ReadDir(name)
+	r.record(Entry{Op: "ReadDir", Path: name, Err: err}, start)
+	return entries, err
+}
+
+// Stat implements iofs.StatFS
+func (r *Recorder) Stat(name string) (iofs.FileInfo, error) {
+	start := r.clock.Now()
+	info, err := r.fsys.Stat(name)
+	r.record(Entry{Op: "Stat", Path: name, Err: err}, start)
+	return info, err
+}
+
+// Lstat implements fs.SymlinkFS
+func (r *Recorder) Lstat(name string) (iofs.FileInfo, error) {
+	start := r.clock.Now()
+	info, err := fs.Lstat(r.fsys, name)
+	r.record(Entry{Op: "Lstat", Path: name, Err: err}, start)
+	return info, err
+}
+
+// Readlink implements fs.SymlinkFS
+func (r *Recorder) Readlink(name string) (string, error) {
+	start := r.clock.Now()
+	target, err := fs.Readlink(r.fsys, name)
+	r.record(Entry{Op: "Readlink", Path: name, Err: err}, start)
+	return target, err
+}
+
+// Exists implements fs.ExistsFS
+func (r *Recorder) Exists(name string) (bool, error) {
+	start := r.clock.Now()
+	ok, err := r.fsys.Exists(name)
+	r.record(Entry{Op: "Exists", Path: name, Err: err}, start)
+	return ok, err
+}
+
+// Glob implements iofs.GlobFS. The pattern is recorded as the path.
+func (r *Recorder) Glob(pattern string) ([]string, error) {
+	start := r.clock.Now()
+	matches, err := r.fsys.Glob(pattern)
+	r.record(Entry{Op: "Glob", Path: pattern, Err: err}, start)
+	return matches, err
+}
+
+// Sub implements iofs.SubFS. Calls through the sub file system are recorded with the names of the sub file system.
+func (r *Recorder) Sub(dir string) (iofs.FS, error) {
+	start := r.clock.Now()
+	sub, err := r.fsys.Sub(dir)
+	r.record(Entry{Op: "Sub", Path: dir, Err: err}, start)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedSub{fsys: sub, recorder: r}, nil
+}
+
+// Mkdir implements fs.MakeDirFS
+func (r *Recorder) Mkdir(name string, perm iofs.FileMode) error {
+	start := r.clock.Now()
+	err := r.fsys.Mkdir(name, perm)
+	r.record(Entry{Op: "Mkdir", Path: name, Err: err}, start)
+	return err
+}
+
+// MkdirAll implements fs.MakeDirFS
+func (r *Recorder) MkdirAll(name string, perm iofs.FileMode) error {
+	start := r.clock.Now()
+	err := r.fsys.MkdirAll(name, perm)
+	r.record(Entry{Op: "MkdirAll", Path: name, Err: err}, start)
+	return err
+}
+
+// Remove implements fs.RemoveFS
+func (r *Recorder) Remove(name string) error {
+	start := r.clock.Now()
+	err := r.fsys.Remove(name)
+	r.record(Entry{Op: "Remove", Path: name, Err: err}, start)
+	return err
+}
+
+// RemoveAll implements fs.RemoveFS
+func (r *Recorder) RemoveAll(name string) error {
+	start := r.clock.Now()
+	err := r.fsys.RemoveAll(name)
+	r.record(Entry{Op: "RemoveAll", Path: name, Err: err}, start)
+	return err
+}
+
+// Rename implements fs.RenameFS
+func (r *Recorder) Rename(oldpath string, newpath string) error {
+	start := r.clock.Now()
+	err := r.fsys.Rename(oldpath, newpath)
+	r.record(Entry{Op: "Rename", Path: oldpath, NewPath: newpath, Err: err}, start)
+	return err
+}
+
+// Symlink implements fs.SymlinkFS
+func (r *Recorder) Symlink(oldname string, newname string) error {
+	start := r.clock.Now()
+	err := fs.Symlink(r.fsys, oldname, newname)
+	r.record(Entry{Op: "Symlink", Path: oldname, NewPath: newname, Err: err}, start)
+	return err
+}
+
+// Link implements fs.LinkFS
+func (r *Recorder) Link(oldname string, newname string) error {
+	start := r.clock.Now()
+	err := fs.Link(r.fsys, oldname, newname)
+	r.record(Entry{Op: "Link", Path: oldname, NewPath: newname, Err: err}, start)
+	return err
+}
+
+// Chmod implements fs.ChangeFS
+func (r *Recorder) Chmod(name string, mode iofs.FileMode) error {
+	start := r.clock.Now()
+	err := fs.Chmod(r.fsys, name, mode)
+	r.record(Entry{Op: "Chmod", Path: name, Err: err}, start)
+	return err
+}
+
+// Chtimes implements fs.ChangeFS
+func (r *Recorder) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	start := r.clock.Now()
+	err := fs.Chtimes(r.fsys, name, atime, mtime)
+	r.record(Entry{Op: "Chtimes", Path: name, Err: err}, start)
+	return err
+}
+
+// Chown implements fs.ChangeFS
+func (r *Recorder) Chown(name string, uid, gid int) error {
+	start := r.clock.Now()
+	err := fs.Chown(r.fsys, name, uid, gid)
+	r.record(Entry{Op: "Chown", Path: name, Err: err}, start)
+	return err
+}
+
+// Lchown implements fs.ChangeFS
+func (r *Recorder) Lchown(name string, uid, gid int) error {
+	start := r.clock.Now()
+	err := fs.Lchown(r.fsys, name, uid, gid)
+	r.record(Entry{Op: "Lchown", Path: name, Err: err}, start)
+	return err
+}
+
+// recordedSub is the sub file system of a Recorder
+type recordedSub struct {
+	fsys     iofs.FS
+	recorder *Recorder
+}
+
+func (s *recordedSub) Open(name string) (iofs.File, error) {
+	r := s.recorder
+	start := r.clock.Now()
+	file, err := s.fsys.Open(name)
+	r.record(Entry{Op: "Open", Path: name, Flag: os.O_RDONLY, Err: err}, start)
+	if err != nil {
+		return nil, err
+	}
+	if handle, ok := file.(fs.File); ok {
+		return &recordedFile{File: handle, recorder: r}, nil
+	}
+	return file, nil
+}
+
+// recordedFile is a handle whose calls are recorded by the Recorder that opened it
+type recordedFile struct {
+	fs.File
+	recorder *Recorder
+}
+
+// record records a call of the handle
+func (h *recordedFile) record(op string, bytes int64, err error, start time.Time) {
+	h.recorder.record(Entry{Op: op, Path: h.Name(), Bytes: bytes, Err: err}, start)
+}
+
+func (h *recordedFile) Stat() (iofs.FileInfo, error) {
+	start := h.recorder.clock.Now()
+	info, err := h.File.Stat()
+	h.record("Stat", 0, err, start)
+	return info, err
+}
+
+func (h *recordedFile) Read(b []byte) (int, error) {
+	start := h.recorder.clock.Now()
+	n, err := h.File.Read(b)
+	h.record("Read", int64(n), err, start)
+	return n, err
+}
+
+func (h *recordedFile) ReadAt(b []byte, offset int64) (int, error) {
+	start := h.recorder.clock.Now()
+	n, err := h.File.ReadAt(b, offset)
+	h.record("ReadAt", int64(n), err, start)
+	return n, err
+}
+
+func (h *recordedFile) Write(b []byte) (int, error) {
+	start := h.recorder.clock.Now()
+	n, err := h.File.Write(b)
+	h.record("Write", int64(n), err, start)
+	return n, err
+}
+
+func (h *recordedFile) WriteAt(b []byte, offset int64) (int, error) {
+	start := h.recorder.clock.Now()
+	n, err := h.File.WriteAt(b, offset)
+	h.record("WriteAt", int64(n), err, start)
+	return n, err
+}
+
+func (h *recordedFile) WriteString(s string) (int, error) {
+	start := h.recorder.clock.Now()
+	n, err := h.File.WriteString(s)
+	h.record("WriteString", int64(n), err, start)
+	return n, err
+}
+
+func (h *recordedFile) Seek(offset int64, whence int) (int64, error) {
+	start := h.recorder.clock.Now()
+	position, err := h.File.Seek(offset, whence)
+	h.record("Seek", 0, err, start)
+	return position, err
+}
+
+func (h *recordedFile) ReadFrom(reader io.Reader) (int64, error) {
+	start := h.recorder.clock.Now()
+	n, err := h.File.ReadFrom(reader)
+	h.record("ReadFrom", n, err, start)
+	return n, err
+}
+
+func (h *recordedFile) WriteTo(writer io.Writer) (int64, error) {
+	start := h.recorder.clock.Now()
+	n, err := h.File.WriteTo(writer)
+	h.record("WriteTo", n, err, start)
+	return n, err
+}
+
+func (h *recordedFile) ReadDir(n int) ([]iofs.DirEntry, error) {
+	start := h.recorder.clock.Now()
+	entries, err := h.File.ReadDir(n)
+	h.record("ReadDir", 0, err, start)
+	return entries, err
+}
+
+func (h *recordedFile) Readdirnames(n int) ([]string, error) {
+	start := h.recorder.clock.Now()
+	names, err := h.File.Readdirnames(n)
+	h.record("Readdirnames", 0, err, start)
+	return names, err
+}
+
+func (h *recordedFile) Truncate(size int64) error {
+	start := h.recorder.clock.Now()
+	err := h.File.Truncate(size)
+	h.record("Truncate", 0, err, start)
+	return err
+}
+
+func (h *recordedFile) Sync() error {
+	start := h.recorder.clock.Now()
+	err := h.File.Sync()
+	h.record("Sync", 0, err, start)
+	return err
+}
+
+func (h *recordedFile) Close() error {
+	start := h.recorder.clock.Now()
+	err := h.File.Close()
+	h.record("Close", 0, err, start)
+	return err
+}
+
+// matches returns true if the entry has every field set in expected. Errors are compared with errors.Is.
+func (expected Entry) matches(entry Entry) bool {
+	switch {
+	case expected.Op != "" && expected.Op != entry.Op:
+		return false
+	case expected.Path != "" && expected.Path != entry.Path:
+		return false
+	case expected.NewPath != "" && expected.NewPath != entry.NewPath:
+		return false
+	case expected.Flag != 0 && expected.Flag != entry.Flag:
+		return false
+	case expected.Bytes != 0 && expected.Bytes != entry.Bytes:
+		return false
+	case expected.Err != nil && !errors.Is(entry.Err, expected.Err):
+		return false
+	}
+	return true
+}
+
+// RequireRecorded fails the test unless the expected entries were recorded in order. Other calls may be
+// recorded between them and only the fields set in an expected entry are compared.
+func RequireRecorded(t testing.TB, r *Recorder, expected ...Entry) {
+	t.Helper()
+
+	entries := r.Entries()
+	next := 0
+	for _, entry := range entries {
+		if next < len(expected) && expected[next].matches(entry) {
+			next++
+		}
+	}
+	if next == len(expected) {
+		return
+	}
+	require.Fail(t,
+		fmt.Sprintf("expected call %d was not recorded: %s", next, expected[next]),
+		formatEntries(entries))
+}
+
+// RequireNotRecorded fails the test if any recorded entry matches the unexpected entry
+func RequireNotRecorded(t testing.TB, r *Recorder, unexpected Entry) {
+	t.Helper()
+
+	entries := r.Entries()
+	for i, entry := range entries {
+		if unexpected.matches(entry) {
+			require.Fail(t,
+				fmt.Sprintf("unexpected call %d was recorded: %s", i, entry),
+				formatEntries(entries))
+		}
+	}
+}
+
+// formatEntries formats the entries as a numbered trace
+func formatEntries(entries []Entry) string {
+	var sb strings.Builder
+	for i, entry := range entries {
+		fmt.Fprintf(&sb, "%d: %s\n", i, entry)
+	}
+	return sb.String()
+}
@@ -0,0 +1,106 @@
+package fstesting_test
+
+import (
+	iofs "io/fs"
+	stdos "os"
+	"testing"
+
+	"github.com/patrickhuber/go-xplat/clock"
+	"github.com/patrickhuber/go-xplat/console"
+	"github.com/patrickhuber/go-xplat/filepath"
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/fs/fstesting"
+	"github.com/patrickhuber/go-xplat/os"
+	"github.com/patrickhuber/go-xplat/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRecorder(t *testing.T, options ...fstesting.RecorderOption) (*fstesting.Recorder, *filepath.Processor) {
+	processor := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(platform.Linux)))
+	memory := fs.NewMemory(fs.WithProcessor(processor))
+	require.NoError(t, memory.MkdirAll("/gran/parent", 0777))
+	return fstesting.NewRecorder(memory, options...), processor
+}
+
+func TestRecorderConformance(t *testing.T) {
+	recorder, processor := setupRecorder(t)
+	fstesting.NewConformance(recorder, processor).Run(t, "/conformance")
+}
+
+func TestRecorderRecordsCalls(t *testing.T) {
+	recorder, _ := setupRecorder(t, fstesting.WithClock(clock.NewMock()))
+
+	f, err := recorder.OpenFile("/gran/file.txt", stdos.O_WRONLY|stdos.O_CREATE, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("content"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, recorder.Rename("/gran/file.txt", "/gran/parent/file.txt"))
+	_, err = recorder.ReadFile("/gran/missing.txt")
+	require.Error(t, err)
+
+	entries := recorder.Entries()
+	require.Equal(t, []fstesting.Entry{
+		{Op: "OpenFile", Path: "/gran/file.txt", Flag: stdos.O_WRONLY | stdos.O_CREATE},
+		{Op: "Write", Path: "/gran/file.txt", Bytes: 7},
+		{Op: "Close", Path: "/gran/file.txt"},
+		{Op: "Rename", Path: "/gran/file.txt", NewPath: "/gran/parent/file.txt"},
+		{Op: "ReadFile", Path: "/gran/missing.txt", Err: err},
+	}, entries)
+
+	recorder.Reset()
+	require.Empty(t, recorder.Entries())
+}
+
+func TestRecorderWritesTrace(t *testing.T) {
+	c := console.NewMemory()
+	recorder, _ := setupRecorder(t, fstesting.WithWriter(c.Error()), fstesting.WithClock(clock.NewMock()))
+
+	require.NoError(t, recorder.WriteFile("/gran/file.txt", []byte("content"), 0644))
+	f, err := recorder.OpenFile("/gran/file.txt", stdos.O_RDWR|stdos.O_APPEND, 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Error(t, recorder.Remove("/gran/missing.txt"))
+
+	require.Equal(t,
+		"WriteFile /gran/file.txt bytes=7 (0s)\n"+
+			"OpenFile /gran/file.txt flag=O_RDWR|O_APPEND (0s)\n"+
+			"Close /gran/file.txt (0s)\n"+
+			"Remove /gran/missing.txt err=\"remove /gran/missing.txt: file does not exist\" (0s)\n",
+		c.ErrBuffer().String())
+}
+
+func TestRequireRecorded(t *testing.T) {
+	recorder, _ := setupRecorder(t)
+
+	require.NoError(t, recorder.WriteFile("/gran/file.txt", []byte("content"), 0644))
+	require.NoError(t, recorder.Chmod("/gran/file.txt", 0600))
+	require.NoError(t, recorder.Remove("/gran/file.txt"))
+	_, err := recorder.Stat("/gran/file.txt")
+	require.Error(t, err)
+
+	fstesting.RequireRecorded(t, recorder,
+		fstesting.Entry{Op: "WriteFile", Path: "/gran/file.txt", Bytes: 7},
+		fstesting.Entry{Op: "Remove"},
+		fstesting.Entry{Op: "Stat", Err: iofs.ErrNotExist},
+	)
+	fstesting.RequireNotRecorded(t, recorder, fstesting.Entry{Op: "Rename"})
+
+	// the entries must be in order
+	mock := &failureT{TB: t}
+	fstesting.RequireRecorded(mock, recorder,
+		fstesting.Entry{Op: "Remove"},
+		fstesting.Entry{Op: "WriteFile"},
+	)
+	require.True(t, mock.failed)
+}
+
+// failureT records a failure instead of failing the test
+type failureT struct {
+	testing.TB
+	failed bool
+}
+
+func (t *failureT) Helper()                           {}
+func (t *failureT) Errorf(format string, args ...any) { t.failed = true }
+func (t *failureT) FailNow()                          { t.failed = true }
@@ -0,0 +1,59 @@
+package fs
+
+import (
+	iofs "io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+)
+
+// glob matches the pattern one path segment at a time using the path.Match syntax. Directories are listed
+// with readDir and exists reports whether a match without meta characters is present.
+func glob(processor *filepath.Processor, pattern string, readDir func(name string) ([]iofs.DirEntry, error), exists func(name string) bool) ([]string, error) {
+	fp, err := processor.Parser.Parse(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range fp.Segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	matches := []filepath.FilePath{fp.Root()}
+	for _, segment := range fp.Segments {
+		var next []filepath.FilePath
+		for _, match := range matches {
+			if !hasMeta(segment) {
+				next = append(next, join(match, segment))
+				continue
+			}
+			entries, err := readDir(processor.String(match))
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if ok, _ := path.Match(segment, entry.Name()); ok {
+					next = append(next, join(match, entry.Name()))
+				}
+			}
+		}
+		matches = next
+	}
+
+	var results []string
+	for _, match := range matches {
+		name := processor.String(match)
+		if exists(name) {
+			results = append(results, name)
+		}
+	}
+	sort.Strings(results)
+	return results, nil
+}
+
+func hasMeta(segment string) bool {
+	return strings.ContainsAny(segment, `*?[\`)
+}
@@ -0,0 +1,153 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing/fstest"
+)
+
+// ErrSharingViolation is returned when emulating Windows and a file is removed or renamed while it has open handles
+var ErrSharingViolation = errors.New("the process cannot access the file because it is being used by another process")
+
+// maxStackDepth is the number of frames recorded when a handle is opened
+const maxStackDepth = 32
+
+// Handle describes a file handle that has been opened and not yet closed
+type Handle struct {
+	// Name is the name of the file as presented to Open
+	Name string
+	// Flag is the flag the file was opened with
+	Flag int
+	// Stack is the stack trace of the goroutine that opened the file
+	Stack string
+}
+
+// HandleFS is a file system that tracks the handles it has opened. Tests use it to find files that were never closed.
+type HandleFS interface {
+	// OpenHandles returns the handles that are still open in the order they were opened
+	OpenHandles() []Handle
+}
+
+// handles is the set of open handles of a memory file system. It has its own lock so handles can be
+// opened while the memory lock is only held for reading.
+type handles struct {
+	mutex    sync.Mutex
+	open     map[*openFile]struct{}
+	sequence uint64
+}
+
+// newHandle creates a handle to the file and starts tracking it. The stack is recorded from the
+// caller of the memory method that opened the file.
+func (m *memory) newHandle(path string, key string, file *fstest.MapFile, flag int) *openFile {
+	f := &openFile{
+		memory: m,
+		path:   path,
+		key:    key,
+		name:   m.processor.Base(path),
+		file:   file,
+		flag:   flag,
+		stack:  make([]uintptr, maxStackDepth),
+	}
+	// skip runtime.Callers, newHandle and the memory method
+	f.stack = f.stack[:runtime.Callers(3, f.stack)]
+
+	m.handles.mutex.Lock()
+	defer m.handles.mutex.Unlock()
+
+	if m.handles.open == nil {
+		m.handles.open = map[*openFile]struct{}{}
+	}
+	m.handles.sequence++
+	f.id = m.handles.sequence
+	m.handles.open[f] = struct{}{}
+	return f
+}
+
+// release stops tracking the handle
+func (m *memory) release(f *openFile) {
+	m.handles.mutex.Lock()
+	defer m.handles.mutex.Unlock()
+	delete(m.handles.open, f)
+}
+
+// checkSharing returns ErrSharingViolation when emulating Windows and the key or any of its children are open.
+// POSIX platforms allow open files to be removed and renamed. The caller must hold the memory lock.
+func (m *memory) checkSharing(key string) error {
+	if !m.processor.OS.Platform().IsWindows() {
+		return nil
+	}
+
+	m.handles.mutex.Lock()
+	defer m.handles.mutex.Unlock()
+
+	for f := range m.handles.open {
+		if f.key == key || m.isDescendant(f.key, key) {
+			return ErrSharingViolation
+		}
+	}
+	return nil
+}
+
+// moveHandles updates open handles after a rename so they keep referring to the moved entries.
+// The caller must hold the memory lock.
+func (m *memory) moveHandles(oldKey string, newKey string) {
+	m.handles.mutex.Lock()
+	defer m.handles.mutex.Unlock()
+
+	for f := range m.handles.open {
+		if f.key == oldKey || m.isDescendant(f.key, oldKey) {
+			f.key = newKey + f.key[len(oldKey):]
+		}
+	}
+}
+
+// OpenHandles implements HandleFS
+func (m *memory) OpenHandles() []Handle {
+	m.handles.mutex.Lock()
+	open := make([]*openFile, 0, len(m.handles.open))
+	for f := range m.handles.open {
+		open = append(open, f)
+	}
+	m.handles.mutex.Unlock()
+
+	sort.Slice(open, func(i, j int) bool {
+		return open[i].id < open[j].id
+	})
+
+	result := make([]Handle, 0, len(open))
+	for _, f := range open {
+		result = append(result, Handle{
+			Name:  f.path,
+			Flag:  f.flag,
+			Stack: formatStack(f.stack),
+		})
+	}
+	return result
+}
+
+// formatStack formats the program counters in the style of runtime/debug.Stack
+func formatStack(pcs []uintptr) string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// checkClosed returns fs.ErrClosed if the handle was closed. The caller must hold the memory lock.
+func (f *openFile) checkClosed(op string) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.path, Err: fs.ErrClosed}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package fs_test
+
+import (
+	iofs "io/fs"
+	stdos "os"
+	"testing"
+
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/fs/fstesting"
+	"github.com/patrickhuber/go-xplat/os"
+	"github.com/patrickhuber/go-xplat/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryUseAfterClose(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestUseAfterClose(t, "/gran/parent/child", "file.txt")
+}
+
+func TestMemoryOpenHandles(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+
+	tracker, ok := fsys.(fs.HandleFS)
+	require.True(t, ok)
+	require.Empty(t, tracker.OpenHandles())
+
+	created, err := fsys.Create("/gran/parent/created.txt")
+	require.NoError(t, err)
+
+	opened, err := fsys.OpenFile("/gran/parent/created.txt", stdos.O_RDONLY, 0)
+	require.NoError(t, err)
+
+	dir, err := fsys.Open("/gran/parent")
+	require.NoError(t, err)
+
+	handles := tracker.OpenHandles()
+	require.Len(t, handles, 3)
+	require.Equal(t, "/gran/parent/created.txt", handles[0].Name)
+	require.Equal(t, stdos.O_RDWR|stdos.O_CREATE|stdos.O_TRUNC, handles[0].Flag)
+	require.Equal(t, "/gran/parent/created.txt", handles[1].Name)
+	require.Equal(t, stdos.O_RDONLY, handles[1].Flag)
+	require.Equal(t, "/gran/parent", handles[2].Name)
+
+	// the stack starts at the code that opened the file
+	require.Contains(t, handles[0].Stack, "TestMemoryOpenHandles")
+	require.Contains(t, handles[0].Stack, "handle_test.go")
+
+	require.NoError(t, opened.Close())
+	handles = tracker.OpenHandles()
+	require.Len(t, handles, 2)
+	require.Equal(t, "/gran/parent/created.txt", handles[0].Name)
+	require.Equal(t, "/gran/parent", handles[1].Name)
+
+	require.NoError(t, created.Close())
+	require.NoError(t, dir.Close())
+	fstesting.RequireNoOpenHandles(t, fsys)
+}
+
+func TestMemoryFailedOpenIsNotTracked(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+
+	_, err := fsys.Open("/missing.txt")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	fstesting.RequireNoOpenHandles(t, fsys)
+}
+
+func TestMemoryReadDirAfterClose(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+
+	dir, err := fsys.OpenFile("/gran/parent", stdos.O_RDONLY, 0)
+	require.NoError(t, err)
+	require.NoError(t, dir.Close())
+
+	_, err = dir.ReadDir(-1)
+	require.ErrorIs(t, err, iofs.ErrClosed)
+
+	_, err = dir.Readdirnames(-1)
+	require.ErrorIs(t, err, iofs.ErrClosed)
+}
+
+func TestMemoryRemoveOpenFile(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestRemoveOpenFile(t, "/gran/parent/child", "file.txt", "content")
+}
+
+func TestMemoryRenameOpenFile(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestRenameOpenFile(t, "/gran/parent/child", "source.txt", "target.txt", "content")
+}
+
+func TestWindowsRemoveOpenFile(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+		TestRemoveOpenFile(t, `c:\ProgramData\fake\folder`, "file.txt", "content")
+}
+
+func TestWindowsRenameOpenFile(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+		TestRenameOpenFile(t, `c:\ProgramData\fake\folder`, "source.txt", "target.txt", "content")
+}
+
+func TestWindowsSharingViolation(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))
+	require.NoError(t, fsys.MkdirAll(`c:\parent\child`, 0777))
+	require.NoError(t, fsys.WriteFile(`c:\parent\child\file.txt`, []byte("content"), 0644))
+
+	f, err := fsys.Open(`c:\parent\child\file.txt`)
+	require.NoError(t, err)
+
+	// the file and every directory above it are in use
+	err = fsys.Remove(`c:\parent\child\file.txt`)
+	require.ErrorIs(t, err, fs.ErrSharingViolation)
+
+	err = fsys.Rename(`c:\parent\child`, `c:\parent\moved`)
+	require.ErrorIs(t, err, fs.ErrSharingViolation)
+
+	err = fsys.RemoveAll(`c:\parent`)
+	require.ErrorIs(t, err, fs.ErrSharingViolation)
+
+	ok, err := fsys.Exists(`c:\parent\child\file.txt`)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// closing the handle releases the file
+	require.NoError(t, f.Close())
+	require.NoError(t, fsys.RemoveAll(`c:\parent`))
+}
+
+func TestMemoryRenameDirectoryKeepsOpenHandle(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+	require.NoError(t, fsys.WriteFile("/gran/parent/file.txt", []byte("content"), 0644))
+
+	dir, err := fsys.OpenFile("/gran/parent", stdos.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer dir.Close()
+
+	// the handle follows the directory to its new name
+	require.NoError(t, fsys.Rename("/gran/parent", "/gran/moved"))
+	names, err := dir.Readdirnames(-1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"file.txt"}, names)
+}
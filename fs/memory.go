@@ -1,18 +1,36 @@
 package fs
 
 import (
-	"fmt"
+	"errors"
 	"io/fs"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	fstest "testing/fstest"
+	"time"
 
+	"github.com/patrickhuber/go-xplat/clock"
 	"github.com/patrickhuber/go-xplat/filepath"
 )
 
+// maxSymlinkHops is the number of symbolic links followed before resolution fails with ELOOP
+const maxSymlinkHops = 40
+
+// memory is a file system stored in memory. It is safe for concurrent use by multiple goroutines.
 type memory struct {
+	mutex     sync.RWMutex
 	fs        fstest.MapFS
+	names     map[string]string
+	cmp       *filepath.Comparison
 	processor *filepath.Processor
+	clock     clock.Clock
+	enforce   bool
+	uid       int
+	gid       int
+	umask     fs.FileMode
+	handles   handles
 }
 
 func NewMemory(options ...MemoryOption) FS {
@@ -23,9 +41,21 @@ func NewMemory(options ...MemoryOption) FS {
 	if m.processor == nil {
 		m.processor = filepath.NewProcessor()
 	}
+	if m.cmp != nil {
+		// copy the processor so the comparison does not change for other users of it
+		processor := *m.processor
+		processor.Comparison = *m.cmp
+		m.processor = &processor
+	}
+	if m.clock == nil {
+		m.clock = clock.New()
+	}
 	if m.fs == nil {
 		m.fs = fstest.MapFS{}
 	}
+	if m.names == nil {
+		m.names = map[string]string{}
+	}
 	return m
 }
 
@@ -37,47 +67,266 @@ func WithProcessor(processor *filepath.Processor) MemoryOption {
 	}
 }
 
+// WithComparison overrides the comparison of the processor used to match names. Use it to emulate file systems
+// that differ from the platform default, for example filepath.IgnoreCase|filepath.IgnoreNormalization for macOS.
+func WithComparison(cmp filepath.Comparison) MemoryOption {
+	return func(m *memory) {
+		m.cmp = &cmp
+	}
+}
+
+// WithClock sets the clock used to stamp modification times
+func WithClock(c clock.Clock) MemoryOption {
+	return func(m *memory) {
+		m.clock = c
+	}
+}
+
+// WithPermissions enables permission checks for the simulated user uid and group gid.
+// New files are owned by the simulated user. The root user (uid 0) is never denied.
+func WithPermissions(uid, gid int) MemoryOption {
+	return func(m *memory) {
+		m.enforce = true
+		m.uid = uid
+		m.gid = gid
+	}
+}
+
+// WithUmask sets the mask removed from the permissions of new files and directories
+func WithUmask(umask fs.FileMode) MemoryOption {
+	return func(m *memory) {
+		m.umask = umask.Perm()
+	}
+}
+
+// Create implements CreateFS. Like os.Create it opens the name with O_RDWR|O_CREATE|O_TRUNC.
 func (m *memory) Create(name string) (File, error) {
-	original := name
-	name = m.normalizePath(name)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-	file, ok := m.fs[name]
-	if !ok {
-		file = &fstest.MapFile{}
-		m.fs[name] = file
-	}
-	file.Data = nil
-	file.Mode = 0666
-	return &openFile{
-		path: original,
-		infoFile: infoFile{
-			name: m.processor.Base(original),
-			file: file,
-		},
-	}, nil
-}
-
-func (m *memory) canonicalize(path string) (string, error) {
-	fp, err := m.processor.Parser.Parse(path)
+	flag := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	key, file, err := m.open("create", name, flag, 0666)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	path = fp.String(m.processor.Separator)
-	return m.normalizePath(path), nil
+	return m.newHandle(name, key, file, flag), nil
 }
 
+// normalizePath returns the form of the name used as a key. Names the processor's comparison considers equal
+// share a key.
 func (m *memory) normalizePath(name string) string {
-	if m.processor.Comparison == filepath.IgnoreCase {
-		return strings.ToLower(name)
+	return m.processor.Comparison.Normalize(name)
+}
+
+func (m *memory) key(fp filepath.FilePath) string {
+	return m.normalizePath(fp.String(m.processor.Separator))
+}
+
+// resolve returns the key of the named entry. Symbolic links in the parent segments are always followed,
+// the last segment is only followed when follow is true. The last segment does not need to exist.
+func (m *memory) resolve(op string, name string, follow bool) (string, error) {
+	key, _, err := m.resolveName(op, name, follow)
+	return key, err
+}
+
+// resolveName is resolve that also returns the last segment of the resolved path as it was written.
+// Entries are created with this name so case-insensitive file systems preserve the original casing.
+func (m *memory) resolveName(op string, name string, follow bool) (string, string, error) {
+	fp, err := m.parse(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	current := fp.Root()
+	remaining := fp.Segments
+	hops := 0
+
+	for len(remaining) > 0 {
+		// searching a directory requires execute permission
+		if dir, ok := m.fs[m.key(current)]; ok {
+			if err := m.checkPermission(op, name, dir, permExecute); err != nil {
+				return "", "", err
+			}
+		}
+
+		next := join(current, remaining[0])
+		remaining = remaining[1:]
+		last := len(remaining) == 0
+
+		key := m.key(next)
+		f, ok := m.fs[key]
+		if !ok {
+			if last {
+				return key, base(next), nil
+			}
+			return "", "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+
+		if f.Mode&fs.ModeSymlink != 0 && (follow || !last) {
+			hops++
+			if hops > maxSymlinkHops {
+				return "", "", &fs.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+			}
+			target, err := m.processor.Parser.Parse(string(f.Data))
+			if err != nil {
+				return "", "", err
+			}
+			// relative targets are relative to the directory containing the link
+			if target.IsRel() {
+				target = join(current, target.Segments...)
+			}
+			target = join(target, remaining...).Clean()
+			current = target.Root()
+			remaining = target.Segments
+			continue
+		}
+
+		if !last && !f.Mode.IsDir() {
+			return "", "", &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+		}
+		current = next
+	}
+	return m.key(current), base(current), nil
+}
+
+// base returns the last segment of the file path or an empty string for a root
+func base(fp filepath.FilePath) string {
+	if len(fp.Segments) == 0 {
+		return ""
+	}
+	return fp.Segments[len(fp.Segments)-1]
+}
+
+// parse parses the name into a clean absolute file path. Relative names are relative to the working directory.
+func (m *memory) parse(name string) (filepath.FilePath, error) {
+	fp, err := m.processor.Parser.Parse(name)
+	if err != nil {
+		return filepath.FilePath{}, err
+	}
+	if fp.IsRel() {
+		wd, err := m.processor.OS.WorkingDirectory()
+		if err != nil {
+			return filepath.FilePath{}, err
+		}
+		wdp, err := m.processor.Parser.Parse(wd)
+		if err != nil {
+			return filepath.FilePath{}, err
+		}
+		fp = join(wdp, fp.Segments...)
+	}
+	return fp.Clean(), nil
+}
+
+// checkParent returns an error if the parent of the key does not exist, is not a directory or can't be modified
+func (m *memory) checkParent(op string, name string, key string) error {
+	parentKey := m.normalizePath(m.processor.Dir(key))
+	if parentKey == key {
+		return nil
+	}
+	parent, ok := m.fs[parentKey]
+	if !ok {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.Mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	return m.checkPermission(op, name, parent, permWrite|permExecute)
+}
+
+// join appends the segments to a copy of the file path so the original segments are never shared
+func join(fp filepath.FilePath, segments ...string) filepath.FilePath {
+	joined := make([]string, 0, len(fp.Segments)+len(segments))
+	joined = append(joined, fp.Segments...)
+	joined = append(joined, segments...)
+	fp.Segments = joined
+	return fp
+}
+
+// lookup returns the named entry or a not exist error
+func (m *memory) lookup(op string, name string, follow bool) (*fstest.MapFile, error) {
+	key, err := m.resolve(op, name, follow)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := m.fs[key]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return f, nil
+}
+
+// newFile adds an entry named name with the given mode at key and updates the parent's modification time.
+// The umask is removed from the mode of everything except symbolic links.
+func (m *memory) newFile(key string, name string, mode fs.FileMode) *fstest.MapFile {
+	if mode&fs.ModeSymlink == 0 {
+		mode &^= m.umask
+	}
+	now := m.clock.Now()
+	f := &fstest.MapFile{
+		Mode:    mode,
+		ModTime: now,
+		Sys:     &MemoryStat{Uid: m.uid, Gid: m.gid, AccessTime: now, Nlink: 1},
+	}
+	m.fs[key] = f
+	m.names[key] = name
+	m.touchParent(key)
+	return f
+}
+
+// displayName returns the name of the entry with the casing it was created with
+func (m *memory) displayName(key string) string {
+	if name, ok := m.names[key]; ok && name != "" {
+		return name
+	}
+	return m.processor.Base(key)
+}
+
+// deleteEntry removes the name at key without changing the link count. The caller must hold the memory lock.
+func (m *memory) deleteEntry(key string) {
+	delete(m.fs, key)
+	delete(m.names, key)
+}
+
+// unlink removes the name at key and decrements the link count of the file. The data is released once the
+// last name is removed and no handles refer to it. The caller must hold the memory lock.
+func (m *memory) unlink(key string) {
+	if f, ok := m.fs[key]; ok {
+		stat := memoryStat(f)
+		if stat.Nlink > 0 {
+			stat.Nlink--
+		}
+	}
+	m.deleteEntry(key)
+}
+
+// touch sets the modification time of the entry to the current time
+func (m *memory) touch(f *fstest.MapFile) {
+	f.ModTime = m.clock.Now()
+}
+
+// touchParent sets the modification time of the entry's parent directory to the current time
+func (m *memory) touchParent(key string) {
+	parent := m.normalizePath(m.processor.Dir(key))
+	if parent == key {
+		return
+	}
+	if f, ok := m.fs[parent]; ok {
+		m.touch(f)
 	}
-	return name
 }
 
 // Open implements FS
 func (m *memory) Open(name string) (fs.File, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
 	op := "open"
 	original := name
-	name = m.normalizePath(name)
+
+	name, err := m.resolve(op, name, true)
+	if err != nil {
+		return nil, err
+	}
 
 	f, ok := m.fs[name]
 	if !ok {
@@ -87,221 +336,377 @@ func (m *memory) Open(name string) (fs.File, error) {
 			Err:  fs.ErrNotExist,
 		}
 	}
-	return &openFile{
-		path: name,
-		infoFile: infoFile{
-			name: m.processor.Base(name),
-			file: f,
-		},
-	}, nil
+	if err := m.checkPermission(op, original, f, permRead); err != nil {
+		return nil, err
+	}
+	return m.newHandle(original, name, f, os.O_RDONLY), nil
 }
 
-func isReadOnly(mode int) bool {
-	switch {
-	case mode&os.O_APPEND == os.O_APPEND:
-		return false
-	case mode&os.O_CREATE == os.O_CREATE:
-		return false
-	case mode&os.O_TRUNC == os.O_CREATE:
-		return false
-	case mode&os.O_WRONLY == os.O_WRONLY:
-		return false
-	case mode&os.O_RDWR == os.O_RDWR:
-		return false
-	}
-	return true
-}
-
-// OpenFile implements OpenFS
-func (m *memory) OpenFile(name string, mode int, perm fs.FileMode) (File, error) {
-	op := "openFile"
-	original := name
+// OpenFile implements OpenFS. The access mode of the flag is enforced on the returned handle, O_CREATE|O_EXCL
+// fails with fs.ErrExist when the name exists and O_APPEND moves every write to the end of the file.
+func (m *memory) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-	name, err := m.canonicalize(name)
+	key, f, err := m.open("open", name, flag, perm)
 	if err != nil {
 		return nil, err
 	}
+	return m.newHandle(name, key, f, flag), nil
+}
+
+// open returns the key and the file the flag opens. Create, OpenFile and WriteFile share it so they validate
+// the flag and the existing entry the same way. The caller must hold the write lock.
+func (m *memory) open(op string, original string, flag int, perm fs.FileMode) (string, *fstest.MapFile, error) {
+	// like O_CREAT|O_EXCL in open(2), an exclusive create does not follow a final symlink
+	exclusive := flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0
+	name, display, err := m.resolveName(op, original, !exclusive)
+	if err != nil {
+		return "", nil, err
+	}
 
 	f, ok := m.fs[name]
-	if !ok {
-		// for readonly files, if the file doesn't exist return an error
-		if isReadOnly(mode) {
-			return nil, &fs.PathError{
-				Op:   op,
-				Path: original,
-				Err:  fs.ErrNotExist,
-			}
+	switch {
+	case ok && exclusive:
+		return "", nil, &fs.PathError{Op: op, Path: original, Err: fs.ErrExist}
+	case !ok && flag&os.O_CREATE == 0:
+		return "", nil, &fs.PathError{Op: op, Path: original, Err: fs.ErrNotExist}
+	case !ok:
+		if err := m.checkParent(op, original, name); err != nil {
+			return "", nil, err
+		}
+		f = m.newFile(name, display, perm)
+	default:
+		if f.Mode.IsDir() && accessMode(flag) != os.O_RDONLY {
+			return "", nil, &fs.PathError{Op: op, Path: original, Err: syscall.EISDIR}
+		}
+		want := accessPermission(flag)
+		if flag&os.O_TRUNC != 0 {
+			want |= permWrite
+		}
+		if err := m.checkPermission(op, original, f, want); err != nil {
+			return "", nil, err
 		}
-
-		f = &fstest.MapFile{}
-		m.fs[name] = f
 	}
 
 	// truncate if O_TRUNC specified
-	if mode&os.O_TRUNC != 0 {
+	if flag&os.O_TRUNC != 0 && !f.Mode.IsDir() {
 		f.Data = nil
+		m.touch(f)
 	}
-
-	// seek pos
-	offset := 0
-	if mode&os.O_APPEND != 0 {
-		offset = len(f.Data)
-	}
-
-	return &openFile{
-		path:   name,
-		offset: int64(offset),
-		infoFile: infoFile{
-			name: m.processor.Base(name),
-			file: f,
-		},
-	}, nil
+	return name, f, nil
 }
 
-// Rename implements FS
+// Rename implements FS. Directories are moved with all of their children. When the destination exists,
+// POSIX platforms replace it and Windows returns an error.
 func (m *memory) Rename(oldPath string, newPath string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-	var err error
+	op := "rename"
+	linkError := func(err error) error {
+		return &os.LinkError{Op: op, Old: oldPath, New: newPath, Err: err}
+	}
 
-	oldPath, err = m.canonicalize(oldPath)
+	oldKey, err := m.resolve(op, oldPath, false)
 	if err != nil {
-		return err
+		return linkError(unwrap(err))
 	}
 
-	newPath, err = m.canonicalize(newPath)
+	newKey, display, err := m.resolveName(op, newPath, false)
 	if err != nil {
-		return err
+		return linkError(unwrap(err))
 	}
 
-	file, ok := m.fs[oldPath]
+	file, ok := m.fs[oldKey]
 	if !ok {
-		return os.ErrNotExist
+		return linkError(fs.ErrNotExist)
+	}
+
+	// renaming a file to itself only changes the case of the name on case-insensitive file systems
+	if oldKey == newKey {
+		m.names[newKey] = display
+		return nil
+	}
+
+	// the source directory must be writable
+	if err := m.checkParent(op, oldPath, oldKey); err != nil {
+		return linkError(unwrap(err))
+	}
+
+	// a directory can't be moved into itself
+	if file.Mode.IsDir() && m.isDescendant(newKey, oldKey) {
+		return linkError(syscall.EINVAL)
+	}
+
+	// the destination parent must exist and be a directory
+	if err := m.checkParent(op, newPath, newKey); err != nil {
+		return linkError(unwrap(err))
 	}
-	delete(m.fs, oldPath)
-	m.fs[newPath] = file
+
+	// windows does not allow open files to be moved
+	if err := m.checkSharing(oldKey); err != nil {
+		return linkError(err)
+	}
+
+	if existing, ok := m.fs[newKey]; ok {
+		// like rename(2), renaming a name onto another link of the same file does nothing
+		if existing == file && !m.processor.OS.Platform().IsWindows() {
+			return nil
+		}
+		if err := m.canReplace(file, newKey, existing); err != nil {
+			return linkError(err)
+		}
+		m.unlink(newKey)
+	}
+
+	// move the entry and all of its children
+	moves := map[string]*fstest.MapFile{}
+	names := map[string]string{}
+	for key, f := range m.fs {
+		if key == oldKey || m.isDescendant(key, oldKey) {
+			moves[newKey+key[len(oldKey):]] = f
+			names[newKey+key[len(oldKey):]] = m.names[key]
+			m.deleteEntry(key)
+		}
+	}
+	for key, f := range moves {
+		m.fs[key] = f
+		m.names[key] = names[key]
+	}
+	m.names[newKey] = display
+	m.moveHandles(oldKey, newKey)
+
+	m.touchParent(oldKey)
+	m.touchParent(newKey)
 	return nil
 }
 
+// canReplace returns an error if the file can't replace the existing destination
+func (m *memory) canReplace(file *fstest.MapFile, key string, existing *fstest.MapFile) error {
+	if m.processor.OS.Platform().IsWindows() {
+		return fs.ErrExist
+	}
+	switch {
+	case file.Mode.IsDir() && !existing.Mode.IsDir():
+		return syscall.ENOTDIR
+	case !file.Mode.IsDir() && existing.Mode.IsDir():
+		return syscall.EISDIR
+	case existing.Mode.IsDir():
+		// only empty directories can be replaced
+		for k := range m.fs {
+			if m.isDescendant(k, key) {
+				return syscall.ENOTEMPTY
+			}
+		}
+	}
+	return nil
+}
+
+// isDescendant returns true if the key is a child of the parent key at any depth
+func (m *memory) isDescendant(key string, parent string) bool {
+	sep := string(m.processor.Separator)
+	prefix := strings.TrimSuffix(parent, sep) + sep
+	return key != parent && strings.HasPrefix(key, prefix)
+}
+
 // Remove implements FS
 func (m *memory) Remove(path string) error {
-	path, err := m.canonicalize(path)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	op := "remove"
+	original := path
+	path, err := m.resolve(op, path, false)
 	if err != nil {
 		return err
 	}
-	_, ok := m.fs[path]
+	f, ok := m.fs[path]
 	if !ok {
-		return os.ErrNotExist
+		return &fs.PathError{Op: op, Path: original, Err: fs.ErrNotExist}
+	}
+	if err := m.checkParent(op, original, path); err != nil {
+		return err
+	}
+
+	// only empty directories can be removed
+	if f.Mode.IsDir() {
+		for p := range m.fs {
+			if m.isDescendant(p, path) {
+				return &fs.PathError{Op: op, Path: original, Err: syscall.ENOTEMPTY}
+			}
+		}
+	}
+
+	// windows does not allow open files to be removed, posix handles keep the data after the name is gone
+	if err := m.checkSharing(path); err != nil {
+		return &fs.PathError{Op: op, Path: original, Err: err}
 	}
-	delete(m.fs, path)
+	m.unlink(path)
 	return nil
 }
 
 // RemoveAll implements FS
 func (m *memory) RemoveAll(path string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	op := "removeall"
+	original := path
+	path, err := m.resolve(op, path, false)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 	paths := []string{}
 	for p := range m.fs {
-		if strings.HasPrefix(p, path) {
+		if p == path || m.isDescendant(p, path) {
 			paths = append(paths, p)
 		}
 	}
+
+	// every directory containing a removed entry must be writable
+	for _, p := range paths {
+		if err := m.checkParent(op, original, p); err != nil {
+			return err
+		}
+	}
+	if err := m.checkSharing(path); err != nil {
+		return &fs.PathError{Op: op, Path: original, Err: err}
+	}
 	for _, p := range paths {
-		delete(m.fs, p)
+		m.unlink(p)
 	}
 	return nil
 }
 
-// Glob implements FS
+// Glob implements FS. Matching is done one path segment at a time using the path.Match syntax.
 func (m *memory) Glob(pattern string) ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
 
-	return m.fs.Glob(pattern)
+	readDir := func(name string) ([]fs.DirEntry, error) {
+		key, err := m.resolve("glob", name, true)
+		if err != nil {
+			return nil, err
+		}
+		if dir, ok := m.fs[key]; !ok || !dir.Mode.IsDir() {
+			return nil, fs.ErrNotExist
+		}
+		return m.readDir(key), nil
+	}
+	exists := func(name string) bool {
+		key, err := m.resolve("glob", name, false)
+		if err != nil {
+			return false
+		}
+		_, ok := m.fs[key]
+		return ok
+	}
+	return glob(m.processor, pattern, readDir, exists)
 }
 
-// ReadDir implements FS
+// ReadDir implements FS. Entries are sorted by name.
 func (m *memory) ReadDir(name string) ([]fs.DirEntry, error) {
-	// check that we can open the file
-	d, err := m.Open(name)
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	op := "readdir"
+
+	// the directory key with all symbolic links resolved
+	key, err := m.resolve(op, name, true)
 	if err != nil {
 		return nil, err
 	}
-	defer d.Close()
+	dir, ok := m.fs[key]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !dir.Mode.IsDir() {
+		return nil, &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	if err := m.checkPermission(op, name, dir, permRead); err != nil {
+		return nil, err
+	}
+	return m.readDir(key), nil
+}
 
-	// create the list of entries
-	var entries []fs.DirEntry
+// readDir returns the entries of the directory sorted by name. The caller must hold the memory lock.
+func (m *memory) readDir(key string) []fs.DirEntry {
+	entries := []fs.DirEntry{}
 	for path, file := range m.fs {
-		originalPath := path
-		// make sure both are lowered if case insensitive
-		if m.processor.Comparison == filepath.IgnoreCase {
-			path = strings.ToLower(path)
-			name = strings.ToLower(name)
-		}
 
 		// same dir
-		if path == name {
+		if path == key {
 			continue
 		}
 
-		// is the file's the directory the same as the 
-		if m.processor.Dir(path) == name {
+		// is the file's directory the same as the directory
+		if m.normalizePath(m.processor.Dir(path)) == key {
 
-			// get the file name
-			fileName := m.processor.Base(originalPath)
+			// get the file name with its original casing
+			fileName := m.displayName(path)
 
 			// append
-			entries = append(entries, &infoFile{name: fileName, file: file})
+			entries = append(entries, newInfoFile(fileName, file))
 		}
 	}
-	return entries, nil
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
 }
 
 // ReadFile implements FS
 func (m *memory) ReadFile(name string) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
 
-	f, err := m.Open(name)
+	f, err := m.lookup("open", name, true)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-
-	stat, err := f.Stat()
-	if err != nil {
+	if err := m.checkPermission("open", name, f, permRead); err != nil {
 		return nil, err
 	}
-
-	buf := make([]byte, stat.Size())
-	if len(buf) == 0 {
-		return buf, nil
+	if f.Mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
 	}
 
-	_, err = f.Read(buf)
-	if err != nil {
-		return nil, err
-	}
+	buf := make([]byte, len(f.Data))
+	copy(buf, f.Data)
 	return buf, nil
 }
 
-// WriteFile implements FS
+// WriteFile implements FS. Like os.WriteFile it opens the name with O_WRONLY|O_CREATE|O_TRUNC.
 func (m *memory) WriteFile(name string, data []byte, perm os.FileMode) error {
-	name, err := m.canonicalize(name)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, file, err := m.open("writefile", name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
 		return err
 	}
 
-	file, ok := m.fs[name]
-	if !ok {
-		file = &fstest.MapFile{}
-		m.fs[name] = file
-	}
-
-	file.Data = data
-	file.Mode = perm
+	// copy the data so the caller can't modify the file
+	file.Data = append([]byte(nil), data...)
+	m.touch(file)
 
 	return nil
 }
 
 // Exists implements FS
 func (m *memory) Exists(path string) (bool, error) {
-	name, err := m.canonicalize(path)
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	name, err := m.resolve("exists", path, true)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
 		return false, err
 	}
 	_, ok := m.fs[name]
@@ -310,57 +715,53 @@ func (m *memory) Exists(path string) (bool, error) {
 
 // Stat implements FS
 func (m *memory) Stat(name string) (fs.FileInfo, error) {
-	f, err := m.Open(name)
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	f, err := m.lookup("stat", name, true)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	return f.Stat()
+	return newInfoFile(m.processor.Base(name), f), nil
 }
 
-// Sub implements FS
+// Sub implements FS. The returned file system uses io/fs path rules relative to dir.
 func (m *memory) Sub(dir string) (fs.FS, error) {
-	return m.fs.Sub(dir)
+	return newSubFS(m, m.processor, dir), nil
 }
 
 // Mkdir implements MakeDirFS
 func (m *memory) Mkdir(path string, perm fs.FileMode) error {
-	fp, err := m.processor.Parser.Parse(path)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	op := "mkdir"
+	key, display, err := m.resolveName(op, path, false)
 	if err != nil {
 		return err
 	}
-	accumulator := fp.Root()
+	if _, ok := m.fs[key]; ok {
+		return &fs.PathError{Op: op, Path: path, Err: fs.ErrExist}
+	}
 
-	// check each ancestor path
-	for i := 0; i < len(fp.Segments); i++ {
-		currentPath := accumulator.String(m.processor.Separator)
-		currentPath, err = m.canonicalize(currentPath)
-		if err != nil {
-			return err
-		}
-		_, ok := m.fs[currentPath]
-		if !ok {
-			return errNotExist(currentPath)
-		}
-		seg := fp.Segments[i]
-		fpseg, err := m.processor.Parser.Parse(seg)
-		if err != nil {
-			return err
-		}
-		accumulator = accumulator.Join(fpseg)
+	// the parent must exist and be a directory
+	if err := m.checkParent(op, path, key); err != nil {
+		return err
 	}
 
 	// write the segment
-	m.fs[path] = &fstest.MapFile{
-		Mode: perm | fs.ModeDir,
-	}
+	m.newFile(key, display, perm|fs.ModeDir)
 
 	return nil
 }
 
 // MkdirAll implements MakeDirFS
 func (m *memory) MkdirAll(path string, perm fs.FileMode) error {
-	fp, err := m.processor.Parser.Parse(path)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	op := "mkdir"
+	fp, err := m.parse(path)
 	if err != nil {
 		return err
 	}
@@ -368,29 +769,200 @@ func (m *memory) MkdirAll(path string, perm fs.FileMode) error {
 
 	// create each ancestor path
 	for i := 0; i <= len(fp.Segments); i++ {
-		currentPath := accumulator.String(m.processor.Separator)
-		currentPath = m.normalizePath(currentPath)
-		_, ok := m.fs[currentPath]
+		currentPath, display, err := m.resolveName(op, accumulator.String(m.processor.Separator), true)
+		if err != nil {
+			return err
+		}
+		f, ok := m.fs[currentPath]
 
 		if !ok {
-			m.fs[currentPath] = &fstest.MapFile{
-				Mode: perm | fs.ModeDir,
+			if err := m.checkParent(op, path, currentPath); err != nil {
+				return err
 			}
+			m.newFile(currentPath, display, perm|fs.ModeDir)
+		} else if !f.Mode.IsDir() {
+			return &fs.PathError{Op: op, Path: path, Err: syscall.ENOTDIR}
 		}
 		if i == len(fp.Segments) {
 			break
 		}
+		accumulator = join(accumulator, fp.Segments[i])
+	}
+	return nil
+}
 
-		seg := fp.Segments[i]
-		fpseg, err := m.processor.Parser.Parse(seg)
-		if err != nil {
+// Symlink implements SymlinkFS
+func (m *memory) Symlink(oldname, newname string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	op := "symlink"
+	key, display, err := m.resolveName(op, newname, false)
+	if err != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: unwrap(err)}
+	}
+	if _, ok := m.fs[key]; ok {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	if err := m.checkParent(op, newname, key); err != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: unwrap(err)}
+	}
+	link := m.newFile(key, display, fs.ModeSymlink|0777)
+	link.Data = []byte(oldname)
+	return nil
+}
+
+// Link implements LinkFS. Both names refer to the same file so data, mode and times are shared.
+func (m *memory) Link(oldname, newname string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	op := "link"
+	linkError := func(err error) error {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+
+	// like link(2) on linux, a symbolic link in oldname is not followed
+	oldKey, err := m.resolve(op, oldname, false)
+	if err != nil {
+		return linkError(unwrap(err))
+	}
+	file, ok := m.fs[oldKey]
+	if !ok {
+		return linkError(fs.ErrNotExist)
+	}
+	if file.Mode.IsDir() {
+		return linkError(syscall.EPERM)
+	}
+
+	newKey, display, err := m.resolveName(op, newname, false)
+	if err != nil {
+		return linkError(unwrap(err))
+	}
+	if _, ok := m.fs[newKey]; ok {
+		return linkError(fs.ErrExist)
+	}
+	if err := m.checkParent(op, newname, newKey); err != nil {
+		return linkError(unwrap(err))
+	}
+
+	m.fs[newKey] = file
+	m.names[newKey] = display
+	memoryStat(file).Nlink++
+	m.touchParent(newKey)
+	return nil
+}
+
+// Readlink implements SymlinkFS
+func (m *memory) Readlink(name string) (string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	op := "readlink"
+	f, err := m.lookup(op, name, false)
+	if err != nil {
+		return "", err
+	}
+	if f.Mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: op, Path: name, Err: syscall.EINVAL}
+	}
+	return string(f.Data), nil
+}
+
+// Lstat implements SymlinkFS
+func (m *memory) Lstat(name string) (fs.FileInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	op := "lstat"
+	f, err := m.lookup(op, name, false)
+	if err != nil {
+		return nil, err
+	}
+	return newInfoFile(m.processor.Base(name), f), nil
+}
+
+// Chmod implements ChangeFS
+func (m *memory) Chmod(name string, mode fs.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	op := "chmod"
+	f, err := m.lookup(op, name, true)
+	if err != nil {
+		return err
+	}
+	if err := m.checkOwner(op, name, f); err != nil {
+		return err
+	}
+	const mask = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+	f.Mode = (f.Mode &^ mask) | (mode & mask)
+	return nil
+}
+
+// Chtimes implements ChangeFS
+func (m *memory) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	op := "chtimes"
+	f, err := m.lookup(op, name, true)
+	if err != nil {
+		return err
+	}
+	if err := m.checkOwner(op, name, f); err != nil {
+		return err
+	}
+	if !atime.IsZero() {
+		memoryStat(f).AccessTime = atime
+	}
+	if !mtime.IsZero() {
+		f.ModTime = mtime
+	}
+	return nil
+}
+
+// Chown implements ChangeFS
+func (m *memory) Chown(name string, uid, gid int) error {
+	return m.chown("chown", name, uid, gid, true)
+}
+
+// Lchown implements ChangeFS
+func (m *memory) Lchown(name string, uid, gid int) error {
+	return m.chown("lchown", name, uid, gid, false)
+}
+
+func (m *memory) chown(op string, name string, uid, gid int, follow bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	f, err := m.lookup(op, name, follow)
+	if err != nil {
+		return err
+	}
+	stat := memoryStat(f)
+
+	// only root can change the owner, the owner can change the group to their own group
+	if m.enforce && m.uid != 0 {
+		if err := m.checkOwner(op, name, f); err != nil {
 			return err
 		}
-		accumulator = accumulator.Join(fpseg)
+		if (uid != -1 && uid != stat.Uid) || (gid != -1 && gid != m.gid) {
+			return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+		}
+	}
+	if uid != -1 {
+		stat.Uid = uid
+	}
+	if gid != -1 {
+		stat.Gid = gid
 	}
 	return nil
 }
 
-func errNotExist(path string) error {
-	return fmt.Errorf("'%s' %w", path, fs.ErrNotExist)
+func unwrap(err error) error {
+	if perr, ok := err.(*fs.PathError); ok {
+		return perr.Err
+	}
+	return err
 }
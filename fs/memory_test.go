@@ -1,16 +1,28 @@
 package fs_test
 
 import (
+	"fmt"
+	"io"
+	iofs "io/fs"
+	stdos "os"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/patrickhuber/go-xplat/clock"
 	"github.com/patrickhuber/go-xplat/filepath"
 	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/fs/fstesting"
 	"github.com/patrickhuber/go-xplat/os"
 	"github.com/patrickhuber/go-xplat/platform"
+	"github.com/patrickhuber/go-xplat/setup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMemoryMkdirCreatesRootUnix(t *testing.T) {
-	NewConformanceWithPath(
+	fstesting.NewConformance(
 		setupMemory(
 			os.NewMock(
 				os.WithPlatform(platform.Linux)))).
@@ -18,7 +30,7 @@ func TestMemoryMkdirCreatesRootUnix(t *testing.T) {
 }
 
 func TestMemoryMkdirFailsWhenRootNotExists(t *testing.T) {
-	NewConformanceWithPath(
+	fstesting.NewConformance(
 		setupMemory(
 			os.NewMock(
 				os.WithPlatform(platform.Linux)))).
@@ -26,7 +38,7 @@ func TestMemoryMkdirFailsWhenRootNotExists(t *testing.T) {
 }
 
 func TestMemoryMkdirAllCreatesAllDirectories(t *testing.T) {
-	NewConformanceWithPath(
+	fstesting.NewConformance(
 		setupMemory(
 			os.NewMock(
 				os.WithPlatform(platform.Linux)))).
@@ -39,7 +51,7 @@ func TestMemoryMkdirAllCreatesAllDirectories(t *testing.T) {
 }
 
 func TestMemoryWriteFile(t *testing.T) {
-	NewConformanceWithPath(
+	fstesting.NewConformance(
 		setupMemory(
 			os.NewMock(
 				os.WithPlatform(platform.Linux)))).
@@ -47,7 +59,7 @@ func TestMemoryWriteFile(t *testing.T) {
 }
 
 func TestMemoryWriteCanGrow(t *testing.T) {
-	NewConformanceWithPath(
+	fstesting.NewConformance(
 		setupMemory(
 			os.NewMock(
 				os.WithPlatform(platform.Linux)))).
@@ -61,7 +73,7 @@ func TestMemoryWriteCanGrow(t *testing.T) {
 }
 
 func TestMemoryWriteCanOverwriteMiddle(t *testing.T) {
-	NewConformanceWithPath(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).TestWrite(t,
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).TestWrite(t,
 		"/gran/parent/child",
 		"less.txt",
 		[]byte("this is test data"),
@@ -71,7 +83,7 @@ func TestMemoryWriteCanOverwriteMiddle(t *testing.T) {
 }
 
 func TestMemoryWriteCanOverwriteEnd(t *testing.T) {
-	NewConformanceWithPath(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).TestWrite(t,
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).TestWrite(t,
 		"/gran/parent/child",
 		"end.txt",
 		[]byte("this is test data"),
@@ -82,56 +94,718 @@ func TestMemoryWriteCanOverwriteEnd(t *testing.T) {
 }
 
 func TestMemoryReadDir(t *testing.T) {
-	NewConformanceWithPath(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
 		TestReadDir(t,
-			"/gran/parent/child", []file{
-				{"one.txt", []byte("one")},
-				{"two.txt", []byte("two")},
-				{"three.txt", []byte("three")},
-				{"sub/one.txt", []byte("one")},
-			}, []file{
-				{"one.txt", []byte("one")},
-				{"two.txt", []byte("two")},
-				{"three.txt", []byte("three")},
-				{"sub", []byte{}}})
+			"/gran/parent/child", []fstesting.File{
+				{Name: "one.txt", Content: []byte("one")},
+				{Name: "two.txt", Content: []byte("two")},
+				{Name: "three.txt", Content: []byte("three")},
+				{Name: "sub/one.txt", Content: []byte("one")},
+			}, []fstesting.File{
+				{Name: "one.txt", Content: []byte("one")},
+				{Name: "two.txt", Content: []byte("two")},
+				{Name: "three.txt", Content: []byte("three")},
+				{Name: "sub", Content: []byte{}}})
 }
 
 func TestMemoryCanCreateFile(t *testing.T) {
-	NewConformanceWithPath(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
-		TestCanCreateFile(t, "/gran/parent/child", []file{
-			{"one.txt", []byte("one")},
-			{"two.txt", []byte("two")},
-			{"three.txt", []byte("three")},
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestCanCreateFile(t, "/gran/parent/child", []fstesting.File{
+			{Name: "one.txt", Content: []byte("one")},
+			{Name: "two.txt", Content: []byte("two")},
+			{Name: "three.txt", Content: []byte("three")},
 		})
 }
 
 func TestMemoryCanWriteFile(t *testing.T) {
-	NewConformanceWithPath(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
-		TestCanWriteFile(t, "/gran/parent/child", []file{
-			{"one.txt", []byte("one")},
-			{"two.txt", []byte("two")},
-			{"three.txt", []byte("three")},
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestCanWriteFile(t, "/gran/parent/child", []fstesting.File{
+			{Name: "one.txt", Content: []byte("one")},
+			{Name: "two.txt", Content: []byte("two")},
+			{Name: "three.txt", Content: []byte("three")},
 		})
 }
 
 func TestMemoryOpenFileFailsWhenReadOnlyAndNotExists(t *testing.T) {
-	NewConformanceWithPath(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
 		TestOpenFileFailsWhenNotExists(t, "/gran/parent/child", "/gran/parent/child/one.txt")
 
 }
 
 func TestWindowsWillNormalizePath(t *testing.T) {
-	NewConformanceWithPath(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
 		TestWindowsWillNormalizePath(t, `c:/ProgramData/fake/folder`, `test.txt`)
 }
 
 func TestWindowsFileExists(t *testing.T) {
-	NewConformanceWithPath(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
 		TestWindowsFileForwardAndBackwardSlash(t, "c:/ProgramData/fake/folder/test.txt")
 }
 
+func TestMemorySymlinkIsFollowed(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestSymlinkIsFollowed(t, "/gran/parent/child", "target.txt", "link.txt", "content")
+}
+
+func TestMemoryRelativeSymlinkToDirectory(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestRelativeSymlinkToDirectory(t, "/opt/tool", "1.0.0", "current", "tool.txt")
+}
+
+func TestMemoryReadlinkFailsWhenNotSymlink(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestReadlinkFailsWhenNotSymlink(t, "/gran/parent/child", "file.txt")
+}
+
+func TestMemoryRemoveSymlinkKeepsTarget(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestRemoveSymlinkKeepsTarget(t, "/gran/parent/child", "target.txt", "link.txt")
+}
+
+func TestMemorySymlinkLoop(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestSymlinkLoop(t, "/gran/parent/child", "first", "second")
+}
+
+func TestWindowsSymlinkIsFollowed(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+		TestSymlinkIsFollowed(t, `c:\ProgramData\fake\folder`, "target.txt", "link.txt", "content")
+}
+
+func TestMemoryChmod(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestChmod(t, "/gran/parent/child", "tool", 0755)
+}
+
+func TestMemoryChtimes(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestChtimes(t, "/gran/parent/child", "file.txt", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+}
+
+func TestMemoryChmodFailsWhenNotExists(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestChmodFailsWhenNotExists(t, "/gran/parent/child", "missing.txt")
+}
+
+func TestMemoryChownIsReportedBySys(t *testing.T) {
+	fsys, path := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+
+	file := path.Join("/gran/parent", "file.txt")
+	require.NoError(t, fsys.WriteFile(file, []byte("content"), 0644))
+	require.NoError(t, fs.Chown(fsys, file, 1000, 100))
+
+	// -1 leaves the value unchanged
+	require.NoError(t, fs.Chown(fsys, file, -1, 200))
+
+	stat, err := fsys.Stat(file)
+	require.NoError(t, err)
+	sys, ok := stat.Sys().(*fs.MemoryStat)
+	require.True(t, ok)
+	require.Equal(t, 1000, sys.Uid)
+	require.Equal(t, 200, sys.Gid)
+}
+
+func TestMemoryLchownDoesNotFollowSymlink(t *testing.T) {
+	fsys, path := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+
+	target := path.Join("/gran/parent", "target.txt")
+	link := path.Join("/gran/parent", "link.txt")
+	require.NoError(t, fsys.WriteFile(target, []byte("content"), 0644))
+	require.NoError(t, fs.Symlink(fsys, target, link))
+	require.NoError(t, fs.Lchown(fsys, link, 1000, 1000))
+
+	stat, err := fsys.Stat(target)
+	require.NoError(t, err)
+	require.Equal(t, 0, stat.Sys().(*fs.MemoryStat).Uid)
+
+	lstat, err := fs.Lstat(fsys, link)
+	require.NoError(t, err)
+	require.Equal(t, 1000, lstat.Sys().(*fs.MemoryStat).Uid)
+}
+
+func TestMemoryRenameDirectoryMovesChildren(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestRenameDirectoryMovesChildren(t, "/gran/parent", "child", "renamed", []fstesting.File{
+			{Name: "one.txt", Content: []byte("one")},
+			{Name: "sub/two.txt", Content: []byte("two")},
+			{Name: "sub/deeper/three.txt", Content: []byte("three")},
+		})
+}
+
+func TestMemoryRenameDirectoryIntoItselfFails(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestRenameDirectoryIntoItselfFails(t, "/gran/parent", "child")
+}
+
+func TestMemoryRenameFailsWhenDestinationParentNotExists(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestRenameFailsWhenDestinationParentNotExists(t, "/gran/parent", "file.txt", "missing")
+}
+
+func TestMemoryRenameOverwritesFile(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestRenameOverwritesFile(t, "/gran/parent", "from.txt", "to.txt")
+}
+
+func TestWindowsRenameDirectoryMovesChildren(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+		TestRenameDirectoryMovesChildren(t, `c:\ProgramData\fake`, "child", "renamed", []fstesting.File{
+			{Name: "one.txt", Content: []byte("one")},
+			{Name: `sub\two.txt`, Content: []byte("two")},
+		})
+}
+
+func TestWindowsRenameFailsWhenFileExists(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+		TestRenameFailsWhenFileExists(t, `c:\ProgramData\fake`, "from.txt", "to.txt")
+}
+
+func TestMemoryWriteFileFailsWhenParentNotExists(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestWriteFileFailsWhenParentNotExists(t, "/gran/parent", "missing", "file.txt")
+}
+
+func TestMemoryCreateFailsWhenParentNotExists(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestCreateFailsWhenParentNotExists(t, "/gran/parent", "missing", "file.txt")
+}
+
+func TestMemoryOpenFileCreateFailsWhenParentNotExists(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestOpenFileCreateFailsWhenParentNotExists(t, "/gran/parent", "missing", "file.txt")
+}
+
+func TestMemoryWriteFileFailsWhenParentIsFile(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestWriteFileFailsWhenParentIsFile(t, "/gran/parent", "file.txt", "child.txt")
+}
+
+func TestMemoryWriteFileFailsWhenRootNotExists(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	err := fsys.WriteFile("/file.txt", []byte("content"), 0644)
+	require.ErrorIs(t, err, stdos.ErrNotExist)
+}
+
+func TestMemoryRelativePathUsesWorkingDirectory(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(
+		os.WithPlatform(platform.Linux),
+		os.WithWorkingDirectory("/working")))
+	require.NoError(t, fsys.MkdirAll("/working", 0777))
+	require.NoError(t, fsys.WriteFile("file.txt", []byte("content"), 0644))
+
+	content, err := fsys.ReadFile("/working/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+}
+
+func TestWindowsWriteFileFailsWhenParentNotExists(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+		TestWriteFileFailsWhenParentNotExists(t, `c:\ProgramData\fake`, "missing", "file.txt")
+}
+
 func setupMemory(o os.OS) (fs.FS, *filepath.Processor) {
 	processor := filepath.NewProcessorWithOS(o)
 	fs := fs.NewMemory(fs.WithProcessor(processor))
 	return fs, processor
 }
+
+// newProcessor creates a processor for the platform. Darwin emulates the default macOS volumes, which are
+// case and normalization insensitive.
+func newProcessor(p platform.Platform) *filepath.Processor {
+	o := os.NewMock(os.WithPlatform(p))
+	if p == platform.Darwin {
+		return filepath.NewProcessorWithOS(o, filepath.WithComparison(filepath.IgnoreCase|filepath.IgnoreNormalization))
+	}
+	return filepath.NewProcessorWithOS(o)
+}
+
+// setupDarwin creates a memory file system that emulates the default macOS volumes
+func setupDarwin() (fs.FS, *filepath.Processor) {
+	processor := newProcessor(platform.Darwin)
+	return fs.NewMemory(fs.WithProcessor(processor)), processor
+}
+
+func TestMemoryStampsModTime(t *testing.T) {
+	c := clock.NewMock()
+	processor := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(platform.Linux)))
+	fsys := fs.NewMemory(fs.WithProcessor(processor), fs.WithClock(c))
+
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+	requireModTime(t, fsys, "/gran/parent", clock.MockTime)
+
+	// write file stamps the file and the parent directory
+	c.Advance(time.Minute)
+	require.NoError(t, fsys.WriteFile("/gran/parent/file.txt", []byte("content"), 0644))
+	requireModTime(t, fsys, "/gran/parent/file.txt", c.Now())
+	requireModTime(t, fsys, "/gran/parent", c.Now())
+
+	// writes through the handle stamp the file
+	c.Advance(time.Minute)
+	f, err := fsys.OpenFile("/gran/parent/file.txt", stdos.O_RDWR, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("more"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	requireModTime(t, fsys, "/gran/parent/file.txt", c.Now())
+
+	// create stamps the file
+	c.Advance(time.Minute)
+	f, err = fsys.Create("/gran/parent/create.txt")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	requireModTime(t, fsys, "/gran/parent/create.txt", c.Now())
+
+	// mkdir stamps the directory
+	c.Advance(time.Minute)
+	require.NoError(t, fsys.Mkdir("/gran/parent/child", 0777))
+	requireModTime(t, fsys, "/gran/parent/child", c.Now())
+
+	// rename stamps the source and destination directories
+	c.Advance(time.Minute)
+	require.NoError(t, fsys.Rename("/gran/parent/file.txt", "/gran/parent/child/file.txt"))
+	requireModTime(t, fsys, "/gran/parent", c.Now())
+	requireModTime(t, fsys, "/gran/parent/child", c.Now())
+}
+
+func requireModTime(t *testing.T, fsys fs.FS, name string, expected time.Time) {
+	stat, err := fsys.Stat(name)
+	require.NoError(t, err)
+	require.Equal(t, expected, stat.ModTime(), "unexpected mod time for %s", name)
+}
+
+func TestMemoryConcurrentWrites(t *testing.T) {
+	fsys, path := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+
+	const workers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dir := path.Join("/gran/parent", fmt.Sprintf("child%d", i))
+			file := path.Join(dir, "file.txt")
+			assert.NoError(t, fsys.MkdirAll(dir, 0777))
+			assert.NoError(t, fsys.WriteFile(file, []byte("content"), 0644))
+			_, err := fsys.ReadFile(file)
+			assert.NoError(t, err)
+			_, err = fsys.ReadDir("/gran/parent")
+			assert.NoError(t, err)
+			_, err = fsys.Stat(file)
+			assert.NoError(t, err)
+			assert.NoError(t, fsys.Rename(file, path.Join(dir, "renamed.txt")))
+			assert.NoError(t, fsys.RemoveAll(dir))
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := fsys.ReadDir("/gran/parent")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestMemoryConcurrentFileHandle(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+
+	f, err := fsys.Create("/gran/parent/file.txt")
+	require.NoError(t, err)
+	defer f.Close()
+
+	const workers = 20
+	data := []byte("0123456789")
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := f.Write(data)
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, len(data))
+			_, _ = f.ReadAt(buf, 0)
+			_, err := f.Stat()
+			assert.NoError(t, err)
+			_, err = fsys.ReadFile("/gran/parent/file.txt")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	content, err := fsys.ReadFile("/gran/parent/file.txt")
+	require.NoError(t, err)
+	require.Len(t, content, workers*len(data))
+}
+
+func TestMemoryParallelSharedSetup(t *testing.T) {
+	s := setup.NewTest(setup.Platform(platform.Linux))
+	require.NoError(t, s.FS.MkdirAll("/shared", 0777))
+
+	for i := 0; i < 10; i++ {
+		name := s.Path.Join("/shared", fmt.Sprintf("test%d.txt", i))
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.NoError(t, s.FS.WriteFile(name, []byte(name), 0644))
+			content, err := s.FS.ReadFile(name)
+			require.NoError(t, err)
+			require.Equal(t, name, string(content))
+		})
+	}
+}
+
+func TestMemoryWriteFileCopiesData(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran", 0777))
+
+	data := []byte("content")
+	require.NoError(t, fsys.WriteFile("/gran/file.txt", data, 0644))
+	data[0] = 'X'
+
+	content, err := fsys.ReadFile("/gran/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+}
+
+func TestMemoryConformance(t *testing.T) {
+	type test struct {
+		platform platform.Platform
+		root     string
+	}
+	tests := []test{
+		{platform: platform.Linux, root: "/conformance"},
+		{platform: platform.Darwin, root: "/conformance"},
+		{platform: platform.Windows, root: `c:\conformance`},
+	}
+	for _, test := range tests {
+		t.Run(test.platform.String(), func(t *testing.T) {
+			processor := newProcessor(test.platform)
+			fstesting.NewConformance(fs.NewMemory(fs.WithProcessor(processor)), processor).
+				Run(t, test.root)
+		})
+	}
+}
+
+func TestMemoryGlob(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent/child", 0777))
+	require.NoError(t, fsys.MkdirAll("/gran/uncle", 0777))
+	require.NoError(t, fsys.WriteFile("/gran/parent/b.txt", []byte("b"), 0644))
+	require.NoError(t, fsys.WriteFile("/gran/parent/a.txt", []byte("a"), 0644))
+	require.NoError(t, fsys.WriteFile("/gran/parent/c.md", []byte("c"), 0644))
+	require.NoError(t, fsys.WriteFile("/gran/uncle/d.txt", []byte("d"), 0644))
+
+	type test struct {
+		pattern  string
+		expected []string
+	}
+	tests := []test{
+		{pattern: "/gran/parent/*.txt", expected: []string{"/gran/parent/a.txt", "/gran/parent/b.txt"}},
+		{pattern: "/gran/*/*.txt", expected: []string{"/gran/parent/a.txt", "/gran/parent/b.txt", "/gran/uncle/d.txt"}},
+		{pattern: "/gran/parent/[ac]*", expected: []string{"/gran/parent/a.txt", "/gran/parent/c.md", "/gran/parent/child"}},
+		{pattern: "/gran/parent/c.md", expected: []string{"/gran/parent/c.md"}},
+		{pattern: "/gran/parent/missing.md", expected: nil},
+	}
+	for i, test := range tests {
+		matches, err := fsys.Glob(test.pattern)
+		require.NoError(t, err, "test [%d] failed", i)
+		require.Equal(t, test.expected, matches, "test [%d] failed", i)
+	}
+
+	_, err := fsys.Glob("/gran/[")
+	require.Error(t, err)
+}
+
+func TestMemoryOpenDirectoryReadDir(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+	for _, name := range []string{"c.txt", "a.txt", "b.txt"} {
+		require.NoError(t, fsys.WriteFile("/gran/parent/"+name, []byte(name), 0644))
+	}
+
+	f, err := fsys.Open("/gran/parent")
+	require.NoError(t, err)
+	defer f.Close()
+
+	dir, ok := f.(iofs.ReadDirFile)
+	require.True(t, ok)
+
+	entries, err := dir.ReadDir(2)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "a.txt", entries[0].Name())
+	require.Equal(t, "b.txt", entries[1].Name())
+
+	entries, err = dir.ReadDir(2)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "c.txt", entries[0].Name())
+
+	_, err = dir.ReadDir(2)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestMemoryDirFilePaging(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestDirFilePaging(t, "/gran/parent/child", []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"})
+}
+
+func TestWindowsDirFilePaging(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+		TestDirFilePaging(t, `c:\ProgramData\fake\folder`, []string{"a.txt", "b.txt", "c.txt"})
+}
+
+func TestMemoryDirFileFailsWhenNotDirectory(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestDirFileFailsWhenNotDirectory(t, "/gran/parent/child", "file.txt")
+}
+
+func TestMemoryReaddirnamesAll(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+
+	f, err := fsys.OpenFile("/gran/parent", stdos.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	// an empty directory returns no names and no error when n <= 0
+	names, err := f.Readdirnames(0)
+	require.NoError(t, err)
+	require.Empty(t, names)
+
+	// and io.EOF when n > 0
+	_, err = f.Readdirnames(1)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestMemoryWriteAtPastEnd(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestWriteAtPastEnd(t, "/gran/parent/child", "file.txt")
+}
+
+func TestMemorySeekPastEnd(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestSeekPastEnd(t, "/gran/parent/child", "file.txt")
+}
+
+func TestMemoryTruncate(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestTruncate(t, "/gran/parent/child", "file.txt")
+}
+
+func TestWindowsFileNameAndSync(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+		TestFileNameAndSync(t, `c:\ProgramData\fake\folder`, "file.txt")
+}
+
+func TestMemoryReadFromWriteTo(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestReadFromWriteTo(t, "/gran/parent/child", "source.txt", "target.txt", "content")
+}
+
+func TestMemoryOpenFileExclusive(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestOpenFileExclusive(t, "/gran/parent/child", "file.lock")
+}
+
+func TestWindowsOpenFileExclusive(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+		TestOpenFileExclusive(t, `c:\ProgramData\fake\folder`, "file.lock")
+}
+
+func TestMemoryOpenFileFailsWhenNotExistsWithoutCreate(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestOpenFileFailsWhenNotExistsWithoutCreate(t, "/gran/parent/child", "missing.txt")
+}
+
+func TestMemoryOpenFileReadOnlyRejectsWrite(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestOpenFileReadOnlyRejectsWrite(t, "/gran/parent/child", "file.txt")
+}
+
+func TestMemoryOpenFileWriteOnlyRejectsRead(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestOpenFileWriteOnlyRejectsRead(t, "/gran/parent/child", "file.txt")
+}
+
+func TestMemoryOpenFileAppendIgnoresSeek(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestOpenFileAppendIgnoresSeek(t, "/gran/parent/child", "file.txt")
+}
+
+func TestMemoryOpenFileSync(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestOpenFileSync(t, "/gran/parent/child", "file.txt")
+}
+
+func TestMemoryOpenFileExclusiveDoesNotFollowSymlink(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+
+	// a dangling link still counts as existing for an exclusive create
+	require.NoError(t, fs.Symlink(fsys, "/gran/parent/target.txt", "/gran/parent/link.txt"))
+	_, err := fsys.OpenFile("/gran/parent/link.txt", stdos.O_CREATE|stdos.O_EXCL|stdos.O_WRONLY, 0644)
+	require.ErrorIs(t, err, iofs.ErrExist)
+
+	ok, err := fsys.Exists("/gran/parent/target.txt")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestMemoryOpenFileWriteDirectoryFails(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+
+	_, err := fsys.OpenFile("/gran/parent", stdos.O_WRONLY, 0)
+	require.ErrorIs(t, err, syscall.EISDIR)
+}
+
+func TestWindowsCasePreserving(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+		TestCasePreserving(t, `c:\ProgramData\fake\folder`, "MixedCase", "ReadMe.Txt")
+}
+
+func TestDarwinCasePreserving(t *testing.T) {
+	fstesting.NewConformance(setupDarwin()).
+		TestCasePreserving(t, "/Users/fake/folder", "MixedCase", "ReadMe.Txt")
+}
+
+func TestLinuxIsCaseSensitive(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+	require.NoError(t, fsys.WriteFile("/gran/parent/File.txt", []byte("upper"), 0644))
+	require.NoError(t, fsys.WriteFile("/gran/parent/file.txt", []byte("lower"), 0644))
+
+	entries, err := fsys.ReadDir("/gran/parent")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "File.txt", entries[0].Name())
+	require.Equal(t, "file.txt", entries[1].Name())
+}
+
+func TestDarwinGlobPreservesCase(t *testing.T) {
+	fsys, _ := setupDarwin()
+	require.NoError(t, fsys.MkdirAll("/Users/Fake/Documents", 0777))
+	require.NoError(t, fsys.WriteFile("/Users/Fake/Documents/Report.TXT", []byte("report"), 0644))
+
+	// the existing directory keeps its casing when created again with another casing
+	require.NoError(t, fsys.MkdirAll("/users/fake/documents/Archive", 0777))
+
+	matches, err := fsys.Glob("/Users/*/*/*")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"/Users/Fake/Documents/Archive",
+		"/Users/Fake/Documents/Report.TXT",
+	}, matches)
+}
+
+func TestDarwinNormalizationInsensitive(t *testing.T) {
+	fstesting.NewConformance(setupDarwin()).
+		TestNormalizationInsensitive(t, "/Users/fake/folder")
+}
+
+func TestLinuxIsNormalizationSensitive(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/home/jos\u00e9", 0777))
+
+	ok, err := fsys.Exists("/home/jose\u0301")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestMemoryWithComparison(t *testing.T) {
+	o := os.NewMock(os.WithPlatform(platform.Linux))
+	path := filepath.NewProcessorWithOS(o)
+	fsys := fs.NewMemory(
+		fs.WithProcessor(path),
+		fs.WithComparison(filepath.IgnoreNormalization))
+	require.NoError(t, fsys.MkdirAll("/home/jos\u00e9", 0777))
+
+	ok, err := fsys.Exists("/home/jose\u0301")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// case is still significant and the shared processor is unchanged
+	ok, err = fsys.Exists("/home/JOS\u00c9")
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, filepath.CaseSensitive, path.Comparison)
+}
+
+func TestMemoryLinkSharesData(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestLinkSharesData(t, "/gran/parent/child", "file.txt", "link.txt")
+}
+
+func TestWindowsLinkSharesData(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Windows)))).
+		TestLinkSharesData(t, `c:\ProgramData\fake\folder`, "file.txt", "link.txt")
+}
+
+func TestMemoryLinkFails(t *testing.T) {
+	fstesting.NewConformance(setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))).
+		TestLinkFails(t, "/gran/parent/child", "file.txt", "dir")
+}
+
+func TestMemoryLinkCount(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+	require.NoError(t, fsys.WriteFile("/gran/parent/file.txt", []byte("content"), 0644))
+
+	nlink := func(name string) int {
+		stat, err := fsys.Stat(name)
+		require.NoError(t, err)
+		sys, ok := stat.Sys().(*fs.MemoryStat)
+		require.True(t, ok)
+		return sys.Nlink
+	}
+	require.Equal(t, 1, nlink("/gran/parent/file.txt"))
+
+	require.NoError(t, fs.Link(fsys, "/gran/parent/file.txt", "/gran/parent/one.txt"))
+	require.NoError(t, fs.Link(fsys, "/gran/parent/one.txt", "/gran/parent/two.txt"))
+	require.Equal(t, 3, nlink("/gran/parent/file.txt"))
+	require.Equal(t, 3, nlink("/gran/parent/two.txt"))
+
+	// renaming onto another name of the same file does nothing
+	require.NoError(t, fsys.Rename("/gran/parent/one.txt", "/gran/parent/two.txt"))
+	require.Equal(t, 3, nlink("/gran/parent/one.txt"))
+
+	require.NoError(t, fsys.Remove("/gran/parent/file.txt"))
+	require.Equal(t, 2, nlink("/gran/parent/one.txt"))
+
+	// replacing a name by rename drops its link
+	require.NoError(t, fsys.WriteFile("/gran/parent/other.txt", []byte("other"), 0644))
+	require.NoError(t, fsys.Rename("/gran/parent/other.txt", "/gran/parent/two.txt"))
+	require.Equal(t, 1, nlink("/gran/parent/one.txt"))
+
+	// an open handle keeps the data of the last name
+	f, err := fsys.Open("/gran/parent/one.txt")
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, fsys.RemoveAll("/gran/parent"))
+
+	stat, err := f.Stat()
+	require.NoError(t, err)
+	require.Equal(t, 0, stat.Sys().(*fs.MemoryStat).Nlink)
+
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+}
+
+func TestMemoryLinkDoesNotFollowSymlink(t *testing.T) {
+	fsys, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0777))
+	require.NoError(t, fsys.WriteFile("/gran/parent/target.txt", []byte("content"), 0644))
+	require.NoError(t, fs.Symlink(fsys, "/gran/parent/target.txt", "/gran/parent/symlink.txt"))
+
+	require.NoError(t, fs.Link(fsys, "/gran/parent/symlink.txt", "/gran/parent/hardlink.txt"))
+
+	stat, err := fs.Lstat(fsys, "/gran/parent/hardlink.txt")
+	require.NoError(t, err)
+	require.NotZero(t, stat.Mode()&iofs.ModeSymlink)
+}
@@ -4,6 +4,10 @@ import (
 	"errors"
 	iofs "io/fs"
 	"os"
+	stdfilepath "path/filepath"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/filepath"
 )
 
 type osfs struct {
@@ -45,7 +49,7 @@ func (*osfs) RemoveAll(path string) error {
 
 // Glob implements FS
 func (o *osfs) Glob(pattern string) ([]string, error) {
-	return iofs.Glob(o, pattern)
+	return stdfilepath.Glob(pattern)
 }
 
 // ReadDir implements FS
@@ -83,7 +87,7 @@ func (*osfs) Exists(path string) (bool, error) {
 
 // Sub implements FS
 func (o *osfs) Sub(dir string) (iofs.FS, error) {
-	return iofs.Sub(o, dir)
+	return newSubFS(o, filepath.NewProcessor(), dir), nil
 }
 
 // Mkdir implements MakeDirFS
@@ -95,3 +99,43 @@ func (o *osfs) Mkdir(path string, perm iofs.FileMode) error {
 func (o *osfs) MkdirAll(path string, perm iofs.FileMode) error {
 	return os.MkdirAll(path, perm)
 }
+
+// Symlink implements SymlinkFS
+func (o *osfs) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+// Link implements LinkFS
+func (o *osfs) Link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
+// Readlink implements SymlinkFS
+func (o *osfs) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+// Lstat implements SymlinkFS
+func (o *osfs) Lstat(name string) (iofs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+// Chmod implements ChangeFS
+func (o *osfs) Chmod(name string, mode iofs.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+// Chtimes implements ChangeFS
+func (o *osfs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// Chown implements ChangeFS
+func (o *osfs) Chown(name string, uid, gid int) error {
+	return os.Chown(name, uid, gid)
+}
+
+// Lchown implements ChangeFS
+func (o *osfs) Lchown(name string, uid, gid int) error {
+	return os.Lchown(name, uid, gid)
+}
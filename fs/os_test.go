@@ -0,0 +1,198 @@
+package fs_test
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/fs/fstesting"
+)
+
+func TestOSMkdirAllCreatesAllDirectories(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestMkdirAllCreatesAllDirectories(t, path.Join(root, "gran/parent/child"), []string{
+		root,
+		path.Join(root, "gran"),
+		path.Join(root, "gran/parent"),
+		path.Join(root, "gran/parent/child"),
+	})
+}
+
+func TestOSMkdirFailsWhenRootNotExists(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestMkdirFailsWhenRootNotExists(t, path.Join(root, "missing/test"))
+}
+
+func TestOSWriteFile(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestWriteFile(t, path.Join(root, "gran/parent/child"), "file.txt", "file")
+}
+
+func TestOSWriteCanGrow(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestWrite(t,
+		path.Join(root, "gran/parent/child"),
+		"grow.txt",
+		[]byte("this is test data"),
+		7,
+		[]byte(" more data than expected"),
+		[]byte("this is more data than expected"))
+}
+
+func TestOSWriteCanOverwriteMiddle(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestWrite(t,
+		path.Join(root, "gran/parent/child"),
+		"less.txt",
+		[]byte("this is test data"),
+		8,
+		[]byte("also"),
+		[]byte("this is also data"))
+}
+
+func TestOSReadDir(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestReadDir(t,
+		path.Join(root, "gran/parent/child"), []fstesting.File{
+			{Name: "one.txt", Content: []byte("one")},
+			{Name: "two.txt", Content: []byte("two")},
+			{Name: "three.txt", Content: []byte("three")},
+			{Name: "sub/one.txt", Content: []byte("one")},
+		}, []fstesting.File{
+			{Name: "one.txt", Content: []byte("one")},
+			{Name: "two.txt", Content: []byte("two")},
+			{Name: "three.txt", Content: []byte("three")},
+			{Name: "sub", Content: []byte{}}})
+}
+
+func TestOSCanWriteFile(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestCanWriteFile(t, path.Join(root, "gran/parent/child"), []fstesting.File{
+		{Name: "one.txt", Content: []byte("one")},
+		{Name: "two.txt", Content: []byte("two")},
+		{Name: "three.txt", Content: []byte("three")},
+	})
+}
+
+func TestOSOpenFileFailsWhenReadOnlyAndNotExists(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestOpenFileFailsWhenNotExists(t, path.Join(root, "gran/parent/child"), path.Join(root, "gran/parent/child/one.txt"))
+}
+
+func TestOSSymlinkIsFollowed(t *testing.T) {
+	skipOnWindows(t)
+	c, path, root := setupOS(t)
+	c.TestSymlinkIsFollowed(t, path.Join(root, "gran/parent/child"), "target.txt", "link.txt", "content")
+}
+
+func TestOSRelativeSymlinkToDirectory(t *testing.T) {
+	skipOnWindows(t)
+	c, path, root := setupOS(t)
+	c.TestRelativeSymlinkToDirectory(t, path.Join(root, "opt/tool"), "1.0.0", "current", "tool.txt")
+}
+
+func TestOSSymlinkLoop(t *testing.T) {
+	skipOnWindows(t)
+	c, path, root := setupOS(t)
+	c.TestSymlinkLoop(t, path.Join(root, "gran/parent/child"), "first", "second")
+}
+
+func TestOSChmod(t *testing.T) {
+	skipOnWindows(t)
+	c, path, root := setupOS(t)
+	c.TestChmod(t, path.Join(root, "gran/parent/child"), "tool", 0755)
+}
+
+func TestOSChtimes(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestChtimes(t, path.Join(root, "gran/parent/child"), "file.txt", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+}
+
+func TestOSRenameDirectoryMovesChildren(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestRenameDirectoryMovesChildren(t, path.Join(root, "gran/parent"), "child", "renamed", []fstesting.File{
+		{Name: "one.txt", Content: []byte("one")},
+		{Name: "sub/two.txt", Content: []byte("two")},
+		{Name: "sub/deeper/three.txt", Content: []byte("three")},
+	})
+}
+
+func TestOSRenameDirectoryIntoItselfFails(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestRenameDirectoryIntoItselfFails(t, path.Join(root, "gran/parent"), "child")
+}
+
+func TestOSRenameFailsWhenDestinationParentNotExists(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestRenameFailsWhenDestinationParentNotExists(t, path.Join(root, "gran/parent"), "file.txt", "missing")
+}
+
+func TestOSWriteFileFailsWhenParentNotExists(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestWriteFileFailsWhenParentNotExists(t, path.Join(root, "gran/parent"), "missing", "file.txt")
+}
+
+func TestOSCreateFailsWhenParentNotExists(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestCreateFailsWhenParentNotExists(t, path.Join(root, "gran/parent"), "missing", "file.txt")
+}
+
+func TestOSOpenFileCreateFailsWhenParentNotExists(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestOpenFileCreateFailsWhenParentNotExists(t, path.Join(root, "gran/parent"), "missing", "file.txt")
+}
+
+func TestOSWriteFileFailsWhenParentIsFile(t *testing.T) {
+	skipOnWindows(t)
+	c, path, root := setupOS(t)
+	c.TestWriteFileFailsWhenParentIsFile(t, path.Join(root, "gran/parent"), "file.txt", "child.txt")
+}
+
+// setupOS creates a conformance test for the OS file system rooted in a temporary directory
+func setupOS(t *testing.T) (*fstesting.Conformance, *filepath.Processor, string) {
+	path := filepath.NewProcessor()
+	return fstesting.NewConformance(fs.NewOS(), path), path, t.TempDir()
+}
+
+func skipOnWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("not supported on windows")
+	}
+}
+
+func TestOSConformance(t *testing.T) {
+	c, _, root := setupOS(t)
+	c.Run(t, root)
+}
+
+func TestOSDirFilePaging(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestDirFilePaging(t, path.Join(root, "gran/parent/child"), []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"})
+}
+
+func TestOSWriteAtPastEnd(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestWriteAtPastEnd(t, path.Join(root, "gran/parent/child"), "file.txt")
+}
+
+func TestOSOpenFileExclusive(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestOpenFileExclusive(t, path.Join(root, "gran/parent/child"), "file.lock")
+}
+
+func TestOSUseAfterClose(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestUseAfterClose(t, path.Join(root, "gran/parent/child"), "file.txt")
+}
+
+func TestOSRemoveOpenFile(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestRemoveOpenFile(t, path.Join(root, "gran/parent/child"), "file.txt", "content")
+}
+
+func TestOSLinkSharesData(t *testing.T) {
+	c, path, root := setupOS(t)
+	c.TestLinkSharesData(t, path.Join(root, "gran/parent/child"), "file.txt", "link.txt")
+}
@@ -0,0 +1,1085 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+)
+
+// Overlay is a copy-on-write file system. Reads fall through to the base layer until a name is changed,
+// all changes are written to the upper layer and the base layer is never modified.
+type Overlay interface {
+	FS
+	// Changes returns the changes pending in the upper layer sorted by path
+	Changes() []Change
+}
+
+// ChangeKind is the kind of change made to a path
+type ChangeKind int
+
+const (
+	// ChangeAdded is a path that did not exist in the base layer
+	ChangeAdded ChangeKind = iota
+	// ChangeModified is a path of the base layer whose data or metadata changed
+	ChangeModified
+	// ChangeRemoved is a path of the base layer that was removed along with all of its children
+	ChangeRemoved
+	// ChangeTypeChanged is a path whose entry changed type, for example from a file to a directory
+	ChangeTypeChanged
+)
+
+// String returns the name of the change kind
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeModified:
+		return "modified"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeTypeChanged:
+		return "type changed"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change is a change made to a path
+type Change struct {
+	Kind ChangeKind
+	Path string
+}
+
+// String returns the change as "<kind> <path>"
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s", c.Kind, c.Path)
+}
+
+// overlay merges a read only base layer with a writable upper layer. Removed base entries are hidden with
+// whiteouts and directories recreated over a whiteout are opaque so base children stay hidden. Symbolic
+// links are resolved by the layer that contains them.
+type overlay struct {
+	mutex     sync.RWMutex
+	base      FS
+	upper     FS
+	processor *filepath.Processor
+	whiteouts map[string]struct{}
+	opaque    map[string]struct{}
+	changes   map[string]Change
+}
+
+type OverlayOption = func(*overlay)
+
+// WithOverlayProcessor sets the processor used to join and compare the names of both layers
+func WithOverlayProcessor(processor *filepath.Processor) OverlayOption {
+	return func(o *overlay) {
+		o.processor = processor
+	}
+}
+
+// NewOverlay creates a copy-on-write file system that reads from base and writes to upper
+func NewOverlay(base FS, upper FS, options ...OverlayOption) Overlay {
+	o := &overlay{
+		base:      base,
+		upper:     upper,
+		whiteouts: map[string]struct{}{},
+		opaque:    map[string]struct{}{},
+		changes:   map[string]Change{},
+	}
+	for _, option := range options {
+		option(o)
+	}
+	if o.processor == nil {
+		o.processor = filepath.NewProcessor()
+	}
+	return o
+}
+
+// abs returns the clean absolute name with the symbolic links of its parent segments resolved in the merged
+// view. Both layers are always called with these names so a link in one layer can refer to a directory in the other.
+func (o *overlay) abs(op string, name string) (string, error) {
+	abs, err := o.processor.Abs(name)
+	if err != nil {
+		return "", &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+	abs, err = o.resolve(abs, false)
+	if err != nil {
+		return "", &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+	return abs, nil
+}
+
+// key returns the form of the absolute name used to track whiteouts and changes
+func (o *overlay) key(abs string) string {
+	return o.processor.Comparison.Normalize(abs)
+}
+
+// parent returns the parent directory of the absolute name and false for a root
+func (o *overlay) parent(abs string) (string, bool) {
+	dir := o.processor.Dir(abs)
+	return dir, o.key(dir) != o.key(abs)
+}
+
+// isDescendant returns true if the key is a child of the parent key at any depth
+func (o *overlay) isDescendant(key string, parent string) bool {
+	sep := string(o.processor.Separator)
+	prefix := strings.TrimSuffix(parent, sep) + sep
+	return key != parent && strings.HasPrefix(key, prefix)
+}
+
+// hidden returns true if the base entry at key was removed or is the child of an opaque directory
+func (o *overlay) hidden(key string) bool {
+	current := key
+	for {
+		if _, ok := o.whiteouts[current]; ok {
+			return true
+		}
+		parent := o.key(o.processor.Dir(current))
+		if parent == current {
+			return false
+		}
+		if _, ok := o.opaque[parent]; ok {
+			return true
+		}
+		current = parent
+	}
+}
+
+// baseLstat returns the base entry unless it is hidden
+func (o *overlay) baseLstat(abs string) (iofs.FileInfo, error) {
+	if o.hidden(o.key(abs)) {
+		return nil, iofs.ErrNotExist
+	}
+	return Lstat(o.base, abs)
+}
+
+// lstat returns the visible entry and the layer that contains it without following a final symbolic link
+func (o *overlay) lstat(abs string) (iofs.FileInfo, FS, error) {
+	if info, err := Lstat(o.upper, abs); err == nil {
+		return info, o.upper, nil
+	}
+	if info, err := o.baseLstat(abs); err == nil {
+		return info, o.base, nil
+	}
+	return nil, nil, iofs.ErrNotExist
+}
+
+// follow resolves the symbolic links of every segment of the name in the merged view
+func (o *overlay) follow(abs string) (string, error) {
+	return o.resolve(abs, true)
+}
+
+// resolve resolves the symbolic links of the parent segments of the absolute name in the merged view and the
+// last segment when follow is true. Resolution stops at the first segment that does not exist and the rest of
+// the name is kept as written so the layers report the error.
+func (o *overlay) resolve(abs string, follow bool) (string, error) {
+	fp, err := o.processor.Parser.Parse(abs)
+	if err != nil {
+		return "", err
+	}
+
+	current := fp.Root()
+	remaining := fp.Segments
+	hops := 0
+
+	for len(remaining) > 0 {
+		next := join(current, remaining[0])
+		remaining = remaining[1:]
+		last := len(remaining) == 0
+		if last && !follow {
+			current = next
+			break
+		}
+
+		nextAbs := o.processor.String(next)
+		info, layer, err := o.lstat(nextAbs)
+		if err != nil {
+			current = join(next, remaining...)
+			break
+		}
+		if info.Mode()&iofs.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return "", syscall.ELOOP
+		}
+		link, err := Readlink(layer, nextAbs)
+		if err != nil {
+			return "", err
+		}
+		target, err := o.processor.Parser.Parse(link)
+		if err != nil {
+			return "", err
+		}
+		// relative targets are relative to the directory containing the link
+		if target.IsRel() {
+			target = join(current, target.Segments...)
+		}
+		target = join(target, remaining...).Clean()
+		current = target.Root()
+		remaining = target.Segments
+	}
+	return o.processor.String(current), nil
+}
+
+// layer returns the layer that contains the name after following symbolic links
+func (o *overlay) layer(op string, name string, follow bool) (string, FS, error) {
+	abs, err := o.abs(op, name)
+	if err != nil {
+		return "", nil, err
+	}
+	if follow {
+		abs, err = o.follow(abs)
+		if err != nil {
+			return "", nil, &iofs.PathError{Op: op, Path: name, Err: err}
+		}
+	}
+	_, layer, err := o.lstat(abs)
+	if err != nil {
+		return "", nil, &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+	return abs, layer, nil
+}
+
+// copyUp copies the entry and its parent directories from the base layer to the upper layer. Data, mode
+// and modification time are preserved. Entries already in the upper layer are left alone.
+func (o *overlay) copyUp(abs string) error {
+	if _, err := Lstat(o.upper, abs); err == nil {
+		return nil
+	}
+	info, err := o.baseLstat(abs)
+	if err != nil {
+		return err
+	}
+	if parent, ok := o.parent(abs); ok {
+		if err := o.copyUp(parent); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case info.IsDir():
+		err = o.upper.Mkdir(abs, info.Mode().Perm())
+	case info.Mode()&iofs.ModeSymlink != 0:
+		var target string
+		target, err = Readlink(o.base, abs)
+		if err != nil {
+			return err
+		}
+		return Symlink(o.upper, target, abs)
+	default:
+		var data []byte
+		data, err = o.base.ReadFile(abs)
+		if err != nil {
+			return err
+		}
+		err = o.upper.WriteFile(abs, data, info.Mode().Perm())
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := o.upper.(ChangeFS); !ok {
+		// the entry was created with the permissions of the base but other metadata can't be copied
+		return nil
+	}
+	if err := Chmod(o.upper, abs, info.Mode()); err != nil {
+		return err
+	}
+	return Chtimes(o.upper, abs, info.ModTime(), info.ModTime())
+}
+
+// copyUpTree copies the entry and all of its visible children to the upper layer
+func (o *overlay) copyUpTree(abs string) error {
+	if err := o.copyUp(abs); err != nil {
+		return err
+	}
+	info, err := Lstat(o.upper, abs)
+	if err != nil || !info.IsDir() {
+		return err
+	}
+	entries, err := o.readDir(abs)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := o.copyUpTree(o.processor.Join(abs, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareCreate makes sure the parent of a new entry exists in the upper layer. It returns true if a base
+// entry was removed from the name.
+func (o *overlay) prepareCreate(op string, name string, abs string) (bool, error) {
+	if parent, ok := o.parent(abs); ok {
+		parent, err := o.follow(parent)
+		if err != nil {
+			return false, &iofs.PathError{Op: op, Path: name, Err: err}
+		}
+		info, _, err := o.lstat(parent)
+		if err != nil {
+			return false, &iofs.PathError{Op: op, Path: name, Err: err}
+		}
+		if !info.IsDir() {
+			return false, &iofs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+		}
+		if err := o.copyUp(parent); err != nil {
+			return false, &iofs.PathError{Op: op, Path: name, Err: unwrap(err)}
+		}
+	}
+	_, removed := o.whiteouts[o.key(abs)]
+	return removed, nil
+}
+
+// replace removes the whiteout of a name created in the upper layer. The new entry is opaque so the
+// children of the removed base entry stay hidden whatever the type of either entry.
+func (o *overlay) replace(abs string, removed bool) {
+	if !removed {
+		return
+	}
+	key := o.key(abs)
+	delete(o.whiteouts, key)
+	o.opaque[key] = struct{}{}
+}
+
+// whiteout hides the base entry and its children
+func (o *overlay) whiteout(abs string) {
+	key := o.key(abs)
+	for k := range o.whiteouts {
+		if o.isDescendant(k, key) {
+			delete(o.whiteouts, k)
+		}
+	}
+	for k := range o.opaque {
+		if k == key || o.isDescendant(k, key) {
+			delete(o.opaque, k)
+		}
+	}
+	o.whiteouts[key] = struct{}{}
+}
+
+// record merges the change with the changes already made to the path
+func (o *overlay) record(abs string, kind ChangeKind) {
+	key := o.key(abs)
+	previous, ok := o.changes[key]
+
+	if kind == ChangeRemoved {
+		for k := range o.changes {
+			if o.isDescendant(k, key) {
+				delete(o.changes, k)
+			}
+		}
+		if ok && previous.Kind == ChangeAdded {
+			delete(o.changes, key)
+			return
+		}
+		o.changes[key] = Change{Kind: ChangeRemoved, Path: abs}
+		return
+	}
+
+	if ok {
+		switch previous.Kind {
+		case ChangeAdded:
+			return
+		case ChangeRemoved:
+			// a removed base entry that was created again
+			kind = o.recreated(abs)
+		}
+	}
+	o.changes[key] = Change{Kind: kind, Path: abs}
+}
+
+// recreated returns the kind of change of a removed base entry that was created again in the upper layer
+func (o *overlay) recreated(abs string) ChangeKind {
+	before, err := Lstat(o.base, abs)
+	if err != nil {
+		return ChangeModified
+	}
+	after, err := Lstat(o.upper, abs)
+	if err != nil || before.Mode().Type() == after.Mode().Type() {
+		return ChangeModified
+	}
+	return ChangeTypeChanged
+}
+
+// recordTree records every entry of the upper layer at or below the name as added
+func (o *overlay) recordTree(abs string) {
+	o.record(abs, ChangeAdded)
+	entries, err := o.upper.ReadDir(abs)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		o.recordTree(o.processor.Join(abs, entry.Name()))
+	}
+}
+
+// Changes implements Overlay
+func (o *overlay) Changes() []Change {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	changes := make([]Change, 0, len(o.changes))
+	for _, change := range o.changes {
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// Open implements FS. Directories list the merged entries of both layers.
+func (o *overlay) Open(name string) (iofs.File, error) {
+	return o.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile implements OpenFileFS. Opening a base file for writing copies it to the upper layer first.
+func (o *overlay) OpenFile(name string, flag int, perm iofs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		return o.openRead(name, flag)
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	op := "open"
+	abs, err := o.abs(op, name)
+	if err != nil {
+		return nil, err
+	}
+	exclusive := flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0
+	if !exclusive {
+		// a missing name or the missing target of a dangling link is created
+		abs, err = o.follow(abs)
+		if err != nil {
+			return nil, &iofs.PathError{Op: op, Path: name, Err: err}
+		}
+	}
+
+	_, _, err = o.lstat(abs)
+	existed := err == nil
+	removed := false
+	switch {
+	case existed && exclusive:
+		return nil, &iofs.PathError{Op: op, Path: name, Err: iofs.ErrExist}
+	case existed:
+		if err := o.copyUp(abs); err != nil {
+			return nil, &iofs.PathError{Op: op, Path: name, Err: unwrap(err)}
+		}
+	case flag&os.O_CREATE == 0:
+		return nil, &iofs.PathError{Op: op, Path: name, Err: iofs.ErrNotExist}
+	default:
+		if removed, err = o.prepareCreate(op, name, abs); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := o.upper.OpenFile(abs, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	o.replace(abs, removed)
+	o.recordWrite(abs, existed)
+	return f, nil
+}
+
+// recordWrite records a change to a name that may have existed before
+func (o *overlay) recordWrite(abs string, existed bool) {
+	if existed {
+		o.record(abs, ChangeModified)
+		return
+	}
+	o.record(abs, ChangeAdded)
+}
+
+// openRead opens the name from the layer that contains it
+func (o *overlay) openRead(name string, flag int) (File, error) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	abs, layer, err := o.layer("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	f, err := layer.OpenFile(abs, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil || !stat.IsDir() {
+		return f, nil
+	}
+	return &overlayDir{File: f, overlay: o, path: abs}, nil
+}
+
+// Create implements CreateFS
+func (o *overlay) Create(name string) (File, error) {
+	return o.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// WriteFile implements WriteFileFS
+func (o *overlay) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	f, err := o.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ReadFile implements iofs.ReadFileFS
+func (o *overlay) ReadFile(name string) ([]byte, error) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	abs, layer, err := o.layer("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return layer.ReadFile(abs)
+}
+
+// Stat implements iofs.StatFS
+func (o *overlay) Stat(name string) (iofs.FileInfo, error) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	abs, layer, err := o.layer("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info, err := layer.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	// the layer reports the name of the link target
+	return &namedInfo{FileInfo: info, name: o.processor.Base(name)}, nil
+}
+
+// Lstat implements SymlinkFS
+func (o *overlay) Lstat(name string) (iofs.FileInfo, error) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	abs, layer, err := o.layer("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return Lstat(layer, abs)
+}
+
+// Readlink implements SymlinkFS
+func (o *overlay) Readlink(name string) (string, error) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	abs, layer, err := o.layer("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	return Readlink(layer, abs)
+}
+
+// Exists implements ExistsFS
+func (o *overlay) Exists(name string) (bool, error) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	_, _, err := o.layer("exists", name, true)
+	if errors.Is(err, iofs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReadDir implements iofs.ReadDirFS. Entries of the upper layer replace base entries with the same name.
+func (o *overlay) ReadDir(name string) ([]iofs.DirEntry, error) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	op := "readdir"
+	abs, err := o.abs(op, name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := o.readDir(abs)
+	if err != nil {
+		return nil, &iofs.PathError{Op: op, Path: name, Err: unwrap(err)}
+	}
+	return entries, nil
+}
+
+// readDir returns the merged entries of the directory sorted by name
+func (o *overlay) readDir(abs string) ([]iofs.DirEntry, error) {
+	abs, err := o.follow(abs)
+	if err != nil {
+		return nil, err
+	}
+	info, _, err := o.lstat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, syscall.ENOTDIR
+	}
+
+	merged := map[string]iofs.DirEntry{}
+	if upper, err := o.upper.ReadDir(abs); err == nil {
+		for _, entry := range upper {
+			merged[o.processor.Comparison.Normalize(entry.Name())] = entry
+		}
+	}
+	if base, err := o.base.ReadDir(abs); err == nil {
+		for _, entry := range base {
+			normalized := o.processor.Comparison.Normalize(entry.Name())
+			if _, ok := merged[normalized]; ok {
+				continue
+			}
+			if o.hidden(o.key(o.processor.Join(abs, entry.Name()))) {
+				continue
+			}
+			merged[normalized] = entry
+		}
+	}
+
+	entries := make([]iofs.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// Glob implements iofs.GlobFS. Matching is done one path segment at a time using the path.Match syntax.
+func (o *overlay) Glob(pattern string) ([]string, error) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	return glob(o.processor, pattern, o.readDir, func(name string) bool {
+		abs, err := o.processor.Abs(name)
+		if err != nil {
+			return false
+		}
+		_, _, err = o.lstat(abs)
+		return err == nil
+	})
+}
+
+// Sub implements iofs.SubFS
+func (o *overlay) Sub(dir string) (iofs.FS, error) {
+	return newSubFS(o, o.processor, dir), nil
+}
+
+// Mkdir implements MakeDirFS
+func (o *overlay) Mkdir(name string, perm iofs.FileMode) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	abs, err := o.abs("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return o.mkdir(name, abs, perm)
+}
+
+// mkdir creates the directory in the upper layer. A directory created over a removed base entry is opaque.
+func (o *overlay) mkdir(name string, abs string, perm iofs.FileMode) error {
+	op := "mkdir"
+	if _, _, err := o.lstat(abs); err == nil {
+		return &iofs.PathError{Op: op, Path: name, Err: iofs.ErrExist}
+	}
+	removed, err := o.prepareCreate(op, name, abs)
+	if err != nil {
+		return err
+	}
+	if err := o.upper.Mkdir(abs, perm); err != nil {
+		return err
+	}
+	o.replace(abs, removed)
+	o.record(abs, ChangeAdded)
+	return nil
+}
+
+// MkdirAll implements MakeDirFS
+func (o *overlay) MkdirAll(name string, perm iofs.FileMode) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	op := "mkdir"
+	abs, err := o.abs(op, name)
+	if err != nil {
+		return err
+	}
+	fp, err := o.processor.Parser.Parse(abs)
+	if err != nil {
+		return err
+	}
+
+	current := fp.Root()
+	for i := 0; i <= len(fp.Segments); i++ {
+		dir := o.processor.String(current)
+		resolved, err := o.follow(dir)
+		if err != nil {
+			return &iofs.PathError{Op: op, Path: name, Err: err}
+		}
+		info, _, err := o.lstat(resolved)
+		switch {
+		case errors.Is(err, iofs.ErrNotExist):
+			if err := o.mkdir(name, dir, perm); err != nil {
+				return err
+			}
+		case err != nil:
+			return &iofs.PathError{Op: op, Path: name, Err: err}
+		case !info.IsDir():
+			return &iofs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+		}
+		if i == len(fp.Segments) {
+			break
+		}
+		current = join(current, fp.Segments[i])
+	}
+	return nil
+}
+
+// Remove implements RemoveFS. Removing a base entry leaves a whiteout that hides it.
+func (o *overlay) Remove(name string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	op := "remove"
+	abs, err := o.abs(op, name)
+	if err != nil {
+		return err
+	}
+	info, _, err := o.lstat(abs)
+	if err != nil {
+		return &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+	if info.IsDir() {
+		entries, err := o.readDir(abs)
+		if err != nil {
+			return &iofs.PathError{Op: op, Path: name, Err: unwrap(err)}
+		}
+		if len(entries) > 0 {
+			return &iofs.PathError{Op: op, Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+	return o.remove(op, name, abs)
+}
+
+// RemoveAll implements RemoveFS
+func (o *overlay) RemoveAll(name string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	op := "removeall"
+	abs, err := o.abs(op, name)
+	if err != nil {
+		return err
+	}
+	if _, _, err := o.lstat(abs); err != nil {
+		return nil
+	}
+	return o.remove(op, name, abs)
+}
+
+// remove removes the entry and its children from the upper layer and hides them in the base layer
+func (o *overlay) remove(op string, name string, abs string) error {
+	if _, err := Lstat(o.upper, abs); err == nil {
+		if err := o.upper.RemoveAll(abs); err != nil {
+			return &iofs.PathError{Op: op, Path: name, Err: unwrap(err)}
+		}
+	}
+	if _, err := o.baseLstat(abs); err == nil {
+		o.whiteout(abs)
+	}
+	o.record(abs, ChangeRemoved)
+	return nil
+}
+
+// Rename implements RenameFS. Base entries are copied to the upper layer before they are moved.
+func (o *overlay) Rename(oldpath string, newpath string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	op := "rename"
+	linkError := func(err error) error {
+		return &os.LinkError{Op: op, Old: oldpath, New: newpath, Err: unwrap(err)}
+	}
+	oldAbs, err := o.abs(op, oldpath)
+	if err != nil {
+		return linkError(err)
+	}
+	newAbs, err := o.abs(op, newpath)
+	if err != nil {
+		return linkError(err)
+	}
+
+	info, _, err := o.lstat(oldAbs)
+	if err != nil {
+		return linkError(err)
+	}
+	if o.key(oldAbs) == o.key(newAbs) {
+		// only the case differs, the upper layer keeps the new name
+		if oldAbs == newAbs {
+			return nil
+		}
+		if err := o.copyUp(oldAbs); err != nil {
+			return linkError(err)
+		}
+		if err := o.upper.Rename(oldAbs, newAbs); err != nil {
+			return linkError(err)
+		}
+		o.record(newAbs, ChangeModified)
+		return nil
+	}
+	if info.IsDir() && o.isDescendant(o.key(newAbs), o.key(oldAbs)) {
+		return linkError(syscall.EINVAL)
+	}
+
+	existing, _, err := o.lstat(newAbs)
+	replaced := err == nil
+	if replaced {
+		if err := o.canReplace(info, newAbs, existing); err != nil {
+			return linkError(err)
+		}
+	}
+
+	// the destination is only removed once the source is in the upper layer so a failed copy keeps it
+	if err := o.copyUpTree(oldAbs); err != nil {
+		return linkError(err)
+	}
+	if replaced {
+		if err := o.remove(op, newpath, newAbs); err != nil {
+			return linkError(err)
+		}
+	}
+	removed, err := o.prepareCreate(op, newpath, newAbs)
+	if err != nil {
+		return linkError(err)
+	}
+	if err := o.upper.Rename(oldAbs, newAbs); err != nil {
+		return linkError(err)
+	}
+	o.replace(newAbs, removed)
+
+	// the old name is gone from both layers
+	if _, err := o.baseLstat(oldAbs); err == nil {
+		o.whiteout(oldAbs)
+	}
+	o.record(oldAbs, ChangeRemoved)
+	o.recordTree(newAbs)
+	return nil
+}
+
+// canReplace returns an error if the entry can't replace the existing destination
+func (o *overlay) canReplace(info iofs.FileInfo, abs string, existing iofs.FileInfo) error {
+	if o.processor.OS.Platform().IsWindows() {
+		return iofs.ErrExist
+	}
+	switch {
+	case info.IsDir() && !existing.IsDir():
+		return syscall.ENOTDIR
+	case !info.IsDir() && existing.IsDir():
+		return syscall.EISDIR
+	case existing.IsDir():
+		entries, err := o.readDir(abs)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return syscall.ENOTEMPTY
+		}
+	}
+	return nil
+}
+
+// Symlink implements SymlinkFS
+func (o *overlay) Symlink(oldname, newname string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	op := "symlink"
+	linkError := func(err error) error {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: unwrap(err)}
+	}
+	abs, err := o.abs(op, newname)
+	if err != nil {
+		return linkError(err)
+	}
+	if _, _, err := o.lstat(abs); err == nil {
+		return linkError(iofs.ErrExist)
+	}
+	removed, err := o.prepareCreate(op, newname, abs)
+	if err != nil {
+		return linkError(err)
+	}
+	if err := Symlink(o.upper, oldname, abs); err != nil {
+		return err
+	}
+	o.replace(abs, removed)
+	o.record(abs, ChangeAdded)
+	return nil
+}
+
+// Link implements LinkFS. The old name is copied to the upper layer so both names share its data.
+func (o *overlay) Link(oldname, newname string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	op := "link"
+	linkError := func(err error) error {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: unwrap(err)}
+	}
+	if _, ok := o.upper.(LinkFS); !ok {
+		return linkError(ErrUnsupported)
+	}
+	oldAbs, err := o.abs(op, oldname)
+	if err != nil {
+		return linkError(err)
+	}
+	newAbs, err := o.abs(op, newname)
+	if err != nil {
+		return linkError(err)
+	}
+	info, _, err := o.lstat(oldAbs)
+	if err != nil {
+		return linkError(err)
+	}
+	if info.IsDir() {
+		return linkError(syscall.EPERM)
+	}
+	if _, _, err := o.lstat(newAbs); err == nil {
+		return linkError(iofs.ErrExist)
+	}
+	if err := o.copyUp(oldAbs); err != nil {
+		return linkError(err)
+	}
+	removed, err := o.prepareCreate(op, newname, newAbs)
+	if err != nil {
+		return linkError(err)
+	}
+	if err := Link(o.upper, oldAbs, newAbs); err != nil {
+		return err
+	}
+	o.replace(newAbs, removed)
+	o.record(newAbs, ChangeAdded)
+	return nil
+}
+
+// change copies the named entry to the upper layer and applies the change to it
+func (o *overlay) change(op string, name string, follow bool, apply func(abs string) error) error {
+	if _, ok := o.upper.(ChangeFS); !ok {
+		return &iofs.PathError{Op: op, Path: name, Err: ErrUnsupported}
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	abs, _, err := o.layer(op, name, follow)
+	if err != nil {
+		return err
+	}
+	if err := o.copyUp(abs); err != nil {
+		return &iofs.PathError{Op: op, Path: name, Err: unwrap(err)}
+	}
+	if err := apply(abs); err != nil {
+		return err
+	}
+	o.record(abs, ChangeModified)
+	return nil
+}
+
+// Chmod implements ChangeFS
+func (o *overlay) Chmod(name string, mode iofs.FileMode) error {
+	return o.change("chmod", name, true, func(abs string) error {
+		return Chmod(o.upper, abs, mode)
+	})
+}
+
+// Chtimes implements ChangeFS
+func (o *overlay) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return o.change("chtimes", name, true, func(abs string) error {
+		return Chtimes(o.upper, abs, atime, mtime)
+	})
+}
+
+// Chown implements ChangeFS
+func (o *overlay) Chown(name string, uid, gid int) error {
+	return o.change("chown", name, true, func(abs string) error {
+		return Chown(o.upper, abs, uid, gid)
+	})
+}
+
+// Lchown implements ChangeFS
+func (o *overlay) Lchown(name string, uid, gid int) error {
+	return o.change("lchown", name, false, func(abs string) error {
+		return Lchown(o.upper, abs, uid, gid)
+	})
+}
+
+// overlayDir is a directory handle that lists the merged entries of both layers
+type overlayDir struct {
+	File
+	overlay *overlay
+	path    string
+	entries []iofs.DirEntry
+	listed  bool
+}
+
+// ReadDir implements DirFile with the paging semantics of *os.File
+func (d *overlayDir) ReadDir(n int) ([]iofs.DirEntry, error) {
+	if !d.listed {
+		// check the handle is still open
+		if _, err := d.File.Stat(); err != nil {
+			return nil, err
+		}
+		d.overlay.mutex.RLock()
+		entries, err := d.overlay.readDir(d.path)
+		d.overlay.mutex.RUnlock()
+		if err != nil {
+			return nil, &iofs.PathError{Op: "readdir", Path: d.File.Name(), Err: unwrap(err)}
+		}
+		d.entries = entries
+		d.listed = true
+	}
+
+	count := len(d.entries)
+	if n > 0 && count == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < count {
+		count = n
+	}
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+// Readdirnames implements DirFile and shares the position of ReadDir
+func (d *overlayDir) Readdirnames(n int) ([]string, error) {
+	entries, err := d.ReadDir(n)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, err
+}
@@ -0,0 +1,309 @@
+package fs_test
+
+import (
+	"io"
+	iofs "io/fs"
+	stdos "os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/fs/fstesting"
+	"github.com/patrickhuber/go-xplat/os"
+	"github.com/patrickhuber/go-xplat/platform"
+	"github.com/stretchr/testify/require"
+)
+
+// setupOverlay creates an overlay of two memory file systems. The base contains /base/one.txt and /base/sub/two.txt.
+func setupOverlay(t *testing.T, p platform.Platform) (fs.Overlay, fs.FS, fs.FS, *filepath.Processor) {
+	processor := newProcessor(p)
+	base := fs.NewMemory(fs.WithProcessor(processor))
+	upper := fs.NewMemory(fs.WithProcessor(processor))
+
+	root, err := processor.Abs("/base")
+	require.NoError(t, err)
+	require.NoError(t, base.MkdirAll(processor.Join(root, "sub"), 0755))
+	require.NoError(t, base.WriteFile(processor.Join(root, "one.txt"), []byte("one"), 0640))
+	require.NoError(t, base.WriteFile(processor.Join(root, "sub", "two.txt"), []byte("two"), 0644))
+
+	return fs.NewOverlay(base, upper, fs.WithOverlayProcessor(processor)), base, upper, processor
+}
+
+func TestOverlayConformance(t *testing.T) {
+	type test struct {
+		platform platform.Platform
+		root     string
+	}
+	tests := []test{
+		{platform: platform.Linux, root: "/conformance"},
+		{platform: platform.Darwin, root: "/conformance"},
+		{platform: platform.Windows, root: `c:\conformance`},
+	}
+	for _, test := range tests {
+		t.Run(test.platform.String(), func(t *testing.T) {
+			overlay, _, _, processor := setupOverlay(t, test.platform)
+			fstesting.NewConformance(overlay, processor).Run(t, test.root)
+		})
+	}
+}
+
+func TestOverlayWriteDoesNotChangeBase(t *testing.T) {
+	overlay, base, upper, _ := setupOverlay(t, platform.Linux)
+
+	require.NoError(t, overlay.WriteFile("/base/one.txt", []byte("changed"), 0644))
+	require.NoError(t, overlay.WriteFile("/base/new.txt", []byte("new"), 0644))
+
+	content, err := overlay.ReadFile("/base/one.txt")
+	require.NoError(t, err)
+	require.Equal(t, "changed", string(content))
+
+	content, err = base.ReadFile("/base/one.txt")
+	require.NoError(t, err)
+	require.Equal(t, "one", string(content))
+
+	ok, err := base.Exists("/base/new.txt")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ok, err = upper.Exists("/base/new.txt")
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestOverlayCopyUpPreservesMetadata(t *testing.T) {
+	overlay, base, upper, _ := setupOverlay(t, platform.Linux)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, fs.Chtimes(base, "/base/one.txt", mtime, mtime))
+
+	f, err := overlay.OpenFile("/base/one.txt", stdos.O_WRONLY|stdos.O_APPEND, 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	info, err := upper.Stat("/base/one.txt")
+	require.NoError(t, err)
+	require.Equal(t, iofs.FileMode(0640), info.Mode())
+	require.True(t, mtime.Equal(info.ModTime()))
+
+	content, err := upper.ReadFile("/base/one.txt")
+	require.NoError(t, err)
+	require.Equal(t, "one", string(content))
+}
+
+func TestOverlayRemoveHidesBaseEntry(t *testing.T) {
+	overlay, base, _, _ := setupOverlay(t, platform.Linux)
+
+	require.NoError(t, overlay.Remove("/base/one.txt"))
+	require.NoError(t, overlay.RemoveAll("/base/sub"))
+
+	for _, name := range []string{"/base/one.txt", "/base/sub", "/base/sub/two.txt"} {
+		ok, err := overlay.Exists(name)
+		require.NoError(t, err)
+		require.False(t, ok, name)
+
+		ok, err = base.Exists(name)
+		require.NoError(t, err)
+		require.True(t, ok, name)
+	}
+
+	entries, err := overlay.ReadDir("/base")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestOverlayRecreatedDirectoryIsOpaque(t *testing.T) {
+	overlay, _, _, _ := setupOverlay(t, platform.Linux)
+
+	require.NoError(t, overlay.RemoveAll("/base/sub"))
+	require.NoError(t, overlay.Mkdir("/base/sub", 0755))
+
+	entries, err := overlay.ReadDir("/base/sub")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	require.Equal(t, []fs.Change{
+		{Kind: fs.ChangeModified, Path: "/base/sub"},
+	}, overlay.Changes())
+}
+
+func TestOverlayFileOverRemovedDirectoryHidesChildren(t *testing.T) {
+	overlay, _, _, _ := setupOverlay(t, platform.Linux)
+
+	require.NoError(t, overlay.RemoveAll("/base/sub"))
+	require.NoError(t, overlay.WriteFile("/base/sub", []byte("file"), 0644))
+
+	_, err := overlay.Stat("/base/sub/two.txt")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+	_, err = overlay.ReadFile("/base/sub/two.txt")
+	require.Error(t, err)
+
+	content, err := overlay.ReadFile("/base/sub")
+	require.NoError(t, err)
+	require.Equal(t, "file", string(content))
+
+	require.Equal(t, []fs.Change{
+		{Kind: fs.ChangeTypeChanged, Path: "/base/sub"},
+	}, overlay.Changes())
+
+	// a directory created again over the file still hides the base children
+	require.NoError(t, overlay.Remove("/base/sub"))
+	require.NoError(t, overlay.Mkdir("/base/sub", 0755))
+	entries, err := overlay.ReadDir("/base/sub")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestOverlayReadDirMergesLayers(t *testing.T) {
+	overlay, _, _, _ := setupOverlay(t, platform.Linux)
+
+	require.NoError(t, overlay.WriteFile("/base/a.txt", []byte("a"), 0644))
+	require.NoError(t, overlay.WriteFile("/base/one.txt", []byte("changed"), 0644))
+
+	entries, err := overlay.ReadDir("/base")
+	require.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.Equal(t, []string{"a.txt", "one.txt", "sub"}, names)
+
+	dir, err := overlay.Open("/base")
+	require.NoError(t, err)
+	defer dir.Close()
+
+	dirFile, ok := dir.(fs.DirFile)
+	require.True(t, ok)
+	first, err := dirFile.Readdirnames(2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.txt", "one.txt"}, first)
+	second, err := dirFile.Readdirnames(2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"sub"}, second)
+	_, err = dirFile.Readdirnames(2)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestOverlayRenameBaseDirectory(t *testing.T) {
+	overlay, base, _, _ := setupOverlay(t, platform.Linux)
+
+	require.NoError(t, overlay.Rename("/base/sub", "/base/moved"))
+
+	content, err := overlay.ReadFile("/base/moved/two.txt")
+	require.NoError(t, err)
+	require.Equal(t, "two", string(content))
+
+	ok, err := overlay.Exists("/base/sub")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ok, err = base.Exists("/base/sub/two.txt")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.Equal(t, []fs.Change{
+		{Kind: fs.ChangeAdded, Path: "/base/moved"},
+		{Kind: fs.ChangeAdded, Path: "/base/moved/two.txt"},
+		{Kind: fs.ChangeRemoved, Path: "/base/sub"},
+	}, overlay.Changes())
+}
+
+func TestOverlayFailedRenameKeepsDestination(t *testing.T) {
+	processor := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(platform.Linux)))
+	base := fs.NewMemory(fs.WithProcessor(processor))
+	require.NoError(t, base.MkdirAll("/base", 0755))
+	require.NoError(t, base.WriteFile("/base/one.txt", []byte("one"), 0644))
+	upper := fstesting.NewFaulty(fs.NewMemory(fs.WithProcessor(processor)), processor)
+	overlay := fs.NewOverlay(base, upper, fs.WithOverlayProcessor(processor))
+	require.NoError(t, overlay.WriteFile("/base/two.txt", []byte("two"), 0644))
+
+	// copying the source to the upper layer fails
+	upper.Inject(fstesting.Rule{Op: "WriteFile", Path: "/base/one.txt", Err: syscall.EIO})
+	require.ErrorIs(t, overlay.Rename("/base/one.txt", "/base/two.txt"), syscall.EIO)
+
+	content, err := overlay.ReadFile("/base/two.txt")
+	require.NoError(t, err)
+	require.Equal(t, "two", string(content))
+	content, err = overlay.ReadFile("/base/one.txt")
+	require.NoError(t, err)
+	require.Equal(t, "one", string(content))
+}
+
+func TestOverlaySymlinkedDirectoryAcrossLayers(t *testing.T) {
+	overlay, base, upper, _ := setupOverlay(t, platform.Linux)
+
+	// the link is in the base and its target only exists in the upper layer
+	require.NoError(t, fs.Symlink(base, "/b", "/a"))
+	require.NoError(t, upper.MkdirAll("/b", 0755))
+	require.NoError(t, upper.WriteFile("/b/file.txt", []byte("upper"), 0644))
+
+	content, err := overlay.ReadFile("/a/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "upper", string(content))
+
+	info, err := overlay.Stat("/a/file.txt")
+	require.NoError(t, err)
+	require.True(t, info.Mode().IsRegular())
+
+	entries, err := overlay.ReadDir("/a")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// the link is in the upper layer and its target is a base directory
+	require.NoError(t, fs.Symlink(overlay, "/base/sub", "/current"))
+	content, err = overlay.ReadFile("/current/two.txt")
+	require.NoError(t, err)
+	require.Equal(t, "two", string(content))
+
+	// writing through the link copies the target directory up
+	require.NoError(t, overlay.WriteFile("/current/two.txt", []byte("changed"), 0644))
+	content, err = upper.ReadFile("/base/sub/two.txt")
+	require.NoError(t, err)
+	require.Equal(t, "changed", string(content))
+
+	content, err = base.ReadFile("/base/sub/two.txt")
+	require.NoError(t, err)
+	require.Equal(t, "two", string(content))
+}
+
+func TestOverlayChanges(t *testing.T) {
+	overlay, _, _, _ := setupOverlay(t, platform.Linux)
+
+	require.NoError(t, overlay.WriteFile("/base/sub/two.txt", []byte("changed"), 0644))
+	require.NoError(t, fs.Chmod(overlay, "/base/one.txt", 0600))
+	require.NoError(t, overlay.MkdirAll("/base/new/child", 0755))
+	require.NoError(t, overlay.WriteFile("/base/temp.txt", []byte("temp"), 0644))
+	require.NoError(t, overlay.Remove("/base/temp.txt"))
+
+	changes := overlay.Changes()
+	require.Equal(t, []fs.Change{
+		{Kind: fs.ChangeAdded, Path: "/base/new"},
+		{Kind: fs.ChangeAdded, Path: "/base/new/child"},
+		{Kind: fs.ChangeModified, Path: "/base/one.txt"},
+		{Kind: fs.ChangeModified, Path: "/base/sub/two.txt"},
+	}, changes)
+	require.Equal(t, "modified /base/one.txt", changes[2].String())
+
+	// removing a directory drops the changes of its children
+	require.NoError(t, overlay.RemoveAll("/base/sub"))
+	require.NoError(t, overlay.RemoveAll("/base/new"))
+	require.Equal(t, []fs.Change{
+		{Kind: fs.ChangeModified, Path: "/base/one.txt"},
+		{Kind: fs.ChangeRemoved, Path: "/base/sub"},
+	}, overlay.Changes())
+}
+
+func TestOverlayGlob(t *testing.T) {
+	overlay, _, _, _ := setupOverlay(t, platform.Linux)
+
+	require.NoError(t, overlay.WriteFile("/base/sub/three.txt", []byte("three"), 0644))
+	require.NoError(t, overlay.Remove("/base/one.txt"))
+
+	matches, err := overlay.Glob("/base/*/*.txt")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/base/sub/three.txt", "/base/sub/two.txt"}, matches)
+
+	matches, err = overlay.Glob("/base/*.txt")
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
@@ -0,0 +1,63 @@
+package fs
+
+import (
+	"io/fs"
+	"os"
+	"testing/fstest"
+)
+
+// permission bits checked against the owner, group and other bits of a file mode
+const (
+	permExecute fs.FileMode = 1 << iota
+	permWrite
+	permRead
+)
+
+// permitted returns true if the simulated user has the requested permission on the file.
+// Permissions are only checked when enforcement is enabled and the user is not root.
+func (m *memory) permitted(f *fstest.MapFile, want fs.FileMode) bool {
+	if !m.enforce || m.uid == 0 {
+		return true
+	}
+	stat := memoryStat(f)
+	perm := f.Mode.Perm()
+	switch {
+	case stat.Uid == m.uid:
+		perm >>= 6
+	case stat.Gid == m.gid:
+		perm >>= 3
+	}
+	return perm&want == want
+}
+
+// checkPermission returns a permission error if the simulated user does not have the requested permission on the file
+func (m *memory) checkPermission(op string, name string, f *fstest.MapFile, want fs.FileMode) error {
+	if m.permitted(f, want) {
+		return nil
+	}
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+}
+
+// checkOwner returns a permission error if the simulated user does not own the file
+func (m *memory) checkOwner(op string, name string, f *fstest.MapFile) error {
+	if !m.enforce || m.uid == 0 || memoryStat(f).Uid == m.uid {
+		return nil
+	}
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+}
+
+// accessMode returns the O_RDONLY, O_WRONLY or O_RDWR part of the flag
+func accessMode(flag int) int {
+	return flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR)
+}
+
+// accessPermission returns the permission required to open a file with the flag
+func accessPermission(flag int) fs.FileMode {
+	switch accessMode(flag) {
+	case os.O_WRONLY:
+		return permWrite
+	case os.O_RDWR:
+		return permRead | permWrite
+	}
+	return permRead
+}
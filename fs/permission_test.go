@@ -0,0 +1,152 @@
+package fs_test
+
+import (
+	stdos "os"
+	"testing"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/os"
+	"github.com/patrickhuber/go-xplat/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPermissionWriteToReadOnlyDirectoryFails(t *testing.T) {
+	fsys := setupPermissions(fs.WithPermissions(1000, 1000))
+	require.NoError(t, fsys.MkdirAll("/home/user/.config", 0755))
+	require.NoError(t, fs.Chmod(fsys, "/home/user/.config", 0555))
+
+	err := fsys.WriteFile("/home/user/.config/settings.yml", []byte("settings"), 0644)
+	require.ErrorIs(t, err, stdos.ErrPermission)
+
+	_, err = fsys.Create("/home/user/.config/settings.yml")
+	require.ErrorIs(t, err, stdos.ErrPermission)
+
+	err = fsys.Mkdir("/home/user/.config/sub", 0755)
+	require.ErrorIs(t, err, stdos.ErrPermission)
+}
+
+func TestPermissionRemoveFromReadOnlyDirectoryFails(t *testing.T) {
+	fsys := setupPermissions(fs.WithPermissions(1000, 1000))
+	require.NoError(t, fsys.MkdirAll("/home/user", 0755))
+	require.NoError(t, fsys.WriteFile("/home/user/file.txt", []byte("content"), 0644))
+	require.NoError(t, fs.Chmod(fsys, "/home/user", 0555))
+
+	require.ErrorIs(t, fsys.Remove("/home/user/file.txt"), stdos.ErrPermission)
+	require.ErrorIs(t, fsys.RemoveAll("/home/user/file.txt"), stdos.ErrPermission)
+	require.ErrorIs(t, fsys.Rename("/home/user/file.txt", "/home/user/renamed.txt"), stdos.ErrPermission)
+}
+
+func TestPermissionReadWithoutReadBitFails(t *testing.T) {
+	fsys := setupPermissions(fs.WithPermissions(1000, 1000))
+	require.NoError(t, fsys.MkdirAll("/home/user", 0755))
+	require.NoError(t, fsys.WriteFile("/home/user/file.txt", []byte("content"), 0200))
+
+	_, err := fsys.ReadFile("/home/user/file.txt")
+	require.ErrorIs(t, err, stdos.ErrPermission)
+
+	_, err = fsys.Open("/home/user/file.txt")
+	require.ErrorIs(t, err, stdos.ErrPermission)
+
+	// write only access is allowed
+	f, err := fsys.OpenFile("/home/user/file.txt", stdos.O_WRONLY, 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestPermissionWriteWithoutWriteBitFails(t *testing.T) {
+	fsys := setupPermissions(fs.WithPermissions(1000, 1000))
+	require.NoError(t, fsys.MkdirAll("/home/user", 0755))
+	require.NoError(t, fsys.WriteFile("/home/user/file.txt", []byte("content"), 0444))
+
+	err := fsys.WriteFile("/home/user/file.txt", []byte("changed"), 0644)
+	require.ErrorIs(t, err, stdos.ErrPermission)
+
+	_, err = fsys.OpenFile("/home/user/file.txt", stdos.O_RDWR, 0)
+	require.ErrorIs(t, err, stdos.ErrPermission)
+
+	_, err = fsys.OpenFile("/home/user/file.txt", stdos.O_RDONLY|stdos.O_TRUNC, 0)
+	require.ErrorIs(t, err, stdos.ErrPermission)
+}
+
+func TestPermissionTraverseWithoutExecuteBitFails(t *testing.T) {
+	fsys := setupPermissions(fs.WithPermissions(1000, 1000))
+	require.NoError(t, fsys.MkdirAll("/home/user", 0755))
+	require.NoError(t, fsys.WriteFile("/home/user/file.txt", []byte("content"), 0644))
+	require.NoError(t, fs.Chmod(fsys, "/home/user", 0644))
+
+	_, err := fsys.Stat("/home/user/file.txt")
+	require.ErrorIs(t, err, stdos.ErrPermission)
+
+	// listing only requires read
+	entries, err := fsys.ReadDir("/home/user")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestPermissionChownRequiresRoot(t *testing.T) {
+	fsys := setupPermissions(fs.WithPermissions(1000, 1000))
+	require.NoError(t, fsys.MkdirAll("/home/user", 0755))
+	require.NoError(t, fsys.WriteFile("/home/user/file.txt", []byte("content"), 0644))
+
+	require.ErrorIs(t, fs.Chown(fsys, "/home/user/file.txt", 0, -1), stdos.ErrPermission)
+	require.ErrorIs(t, fs.Chown(fsys, "/home/user/file.txt", -1, 0), stdos.ErrPermission)
+
+	// the owner can change the group to their own group
+	require.NoError(t, fs.Chown(fsys, "/home/user/file.txt", -1, 1000))
+}
+
+func TestPermissionRootIsNeverDenied(t *testing.T) {
+	fsys := setupPermissions(fs.WithPermissions(0, 0))
+	require.NoError(t, fsys.MkdirAll("/etc", 0755))
+	require.NoError(t, fsys.WriteFile("/etc/file.txt", []byte("content"), 0000))
+	require.NoError(t, fs.Chmod(fsys, "/etc", 0000))
+
+	content, err := fsys.ReadFile("/etc/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+	require.NoError(t, fs.Chown(fsys, "/etc/file.txt", 1000, 1000))
+}
+
+func TestPermissionNotEnforcedByDefault(t *testing.T) {
+	fsys := setupPermissions()
+	require.NoError(t, fsys.MkdirAll("/home/user", 0755))
+	require.NoError(t, fsys.WriteFile("/home/user/file.txt", []byte("content"), 0000))
+	require.NoError(t, fs.Chmod(fsys, "/home/user", 0000))
+
+	content, err := fsys.ReadFile("/home/user/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+}
+
+func TestPermissionUmask(t *testing.T) {
+	fsys := setupPermissions(fs.WithUmask(0022))
+	require.NoError(t, fsys.MkdirAll("/home/user", 0777))
+	require.NoError(t, fsys.WriteFile("/home/user/file.txt", []byte("content"), 0666))
+
+	stat, err := fsys.Stat("/home/user")
+	require.NoError(t, err)
+	require.Equal(t, stdos.FileMode(0755), stat.Mode().Perm())
+
+	stat, err = fsys.Stat("/home/user/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, stdos.FileMode(0644), stat.Mode().Perm())
+}
+
+func TestPermissionNewFilesAreOwnedByUser(t *testing.T) {
+	fsys := setupPermissions(fs.WithPermissions(1000, 100))
+	require.NoError(t, fsys.MkdirAll("/home/user", 0755))
+	require.NoError(t, fsys.WriteFile("/home/user/file.txt", []byte("content"), 0644))
+
+	stat, err := fsys.Stat("/home/user/file.txt")
+	require.NoError(t, err)
+	sys := stat.Sys().(*fs.MemoryStat)
+	require.Equal(t, 1000, sys.Uid)
+	require.Equal(t, 100, sys.Gid)
+}
+
+func setupPermissions(options ...fs.MemoryOption) fs.FS {
+	processor := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(platform.Linux)))
+	options = append([]fs.MemoryOption{fs.WithProcessor(processor)}, options...)
+	return fs.NewMemory(options...)
+}
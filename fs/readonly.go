@@ -0,0 +1,244 @@
+package fs
+
+import (
+	"io"
+	iofs "io/fs"
+	"os"
+	"time"
+)
+
+// readOnly wraps a file system and rejects every change with fs.ErrPermission. Files are opened
+// through handles that also reject writes.
+type readOnly struct {
+	fsys FS
+}
+
+// NewReadOnly returns a file system that can read from fsys but never change it
+func NewReadOnly(fsys FS) FS {
+	return &readOnly{fsys: fsys}
+}
+
+// permission returns the error for a change to the name
+func permission(op string, name string) error {
+	return &iofs.PathError{Op: op, Path: name, Err: iofs.ErrPermission}
+}
+
+// Open implements FS. The returned handle rejects writes.
+func (r *readOnly) Open(name string) (iofs.File, error) {
+	f, err := r.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return readOnlyHandle(f), nil
+}
+
+// OpenFile implements OpenFileFS. Any flag that could change the file is rejected.
+func (r *readOnly) OpenFile(name string, flag int, perm iofs.FileMode) (File, error) {
+	if accessMode(flag) != os.O_RDONLY || flag&(os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, permission("open", name)
+	}
+	f, err := r.fsys.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyFile{File: f}, nil
+}
+
+// Create implements CreateFS
+func (r *readOnly) Create(name string) (File, error) {
+	return nil, permission("open", name)
+}
+
+// WriteFile implements WriteFileFS
+func (r *readOnly) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	return permission("open", name)
+}
+
+// Rename implements RenameFS
+func (r *readOnly) Rename(oldpath string, newpath string) error {
+	return permission("rename", oldpath)
+}
+
+// Remove implements RemoveFS
+func (r *readOnly) Remove(name string) error {
+	return permission("remove", name)
+}
+
+// RemoveAll implements RemoveFS
+func (r *readOnly) RemoveAll(name string) error {
+	return permission("removeall", name)
+}
+
+// Mkdir implements MakeDirFS
+func (r *readOnly) Mkdir(name string, perm iofs.FileMode) error {
+	return permission("mkdir", name)
+}
+
+// MkdirAll implements MakeDirFS
+func (r *readOnly) MkdirAll(name string, perm iofs.FileMode) error {
+	return permission("mkdir", name)
+}
+
+// Symlink implements SymlinkFS. It returns ErrUnsupported if the wrapped file system has no symbolic links.
+func (r *readOnly) Symlink(oldname string, newname string) error {
+	if _, ok := r.fsys.(SymlinkFS); !ok {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrUnsupported}
+	}
+	return permission("symlink", newname)
+}
+
+// Link implements LinkFS. It returns ErrUnsupported if the wrapped file system has no hard links.
+func (r *readOnly) Link(oldname string, newname string) error {
+	if _, ok := r.fsys.(LinkFS); !ok {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: ErrUnsupported}
+	}
+	return permission("link", newname)
+}
+
+// change returns the error for a change to the metadata of the name. A wrapped file system that can't change
+// metadata reports ErrUnsupported instead of ErrPermission.
+func (r *readOnly) change(op string, name string) error {
+	if _, ok := r.fsys.(ChangeFS); !ok {
+		return &iofs.PathError{Op: op, Path: name, Err: ErrUnsupported}
+	}
+	return permission(op, name)
+}
+
+// Chmod implements ChangeFS
+func (r *readOnly) Chmod(name string, mode iofs.FileMode) error {
+	return r.change("chmod", name)
+}
+
+// Chtimes implements ChangeFS
+func (r *readOnly) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return r.change("chtimes", name)
+}
+
+// Chown implements ChangeFS
+func (r *readOnly) Chown(name string, uid, gid int) error {
+	return r.change("chown", name)
+}
+
+// Lchown implements ChangeFS
+func (r *readOnly) Lchown(name string, uid, gid int) error {
+	return r.change("lchown", name)
+}
+
+// ReadFile implements iofs.ReadFileFS
+func (r *readOnly) ReadFile(name string) ([]byte, error) {
+	return r.fsys.ReadFile(name)
+}
+
+// ReadDir implements iofs.ReadDirFS
+func (r *readOnly) ReadDir(name string) ([]iofs.DirEntry, error) {
+	return r.fsys.ReadDir(name)
+}
+
+// Stat implements iofs.StatFS
+func (r *readOnly) Stat(name string) (iofs.FileInfo, error) {
+	return r.fsys.Stat(name)
+}
+
+// Lstat implements SymlinkFS
+func (r *readOnly) Lstat(name string) (iofs.FileInfo, error) {
+	return Lstat(r.fsys, name)
+}
+
+// Readlink implements SymlinkFS
+func (r *readOnly) Readlink(name string) (string, error) {
+	return Readlink(r.fsys, name)
+}
+
+// Exists implements ExistsFS
+func (r *readOnly) Exists(name string) (bool, error) {
+	return r.fsys.Exists(name)
+}
+
+// Glob implements iofs.GlobFS
+func (r *readOnly) Glob(pattern string) ([]string, error) {
+	return r.fsys.Glob(pattern)
+}
+
+// Sub implements iofs.SubFS. Handles opened from the sub file system also reject writes.
+func (r *readOnly) Sub(dir string) (iofs.FS, error) {
+	sub, err := r.fsys.Sub(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlySub{fsys: sub}, nil
+}
+
+// readOnlyHandle wraps the handle so it rejects writes
+func readOnlyHandle(f iofs.File) iofs.File {
+	if file, ok := f.(File); ok {
+		return &readOnlyFile{File: file}
+	}
+	return f
+}
+
+// readOnlyFile is a handle that rejects every write with fs.ErrPermission
+type readOnlyFile struct {
+	File
+}
+
+// Write implements io.Writer
+func (f *readOnlyFile) Write(b []byte) (int, error) {
+	return 0, permission("write", f.Name())
+}
+
+// WriteAt implements io.WriterAt
+func (f *readOnlyFile) WriteAt(b []byte, offset int64) (int, error) {
+	return 0, permission("writeat", f.Name())
+}
+
+// WriteString implements io.StringWriter
+func (f *readOnlyFile) WriteString(s string) (int, error) {
+	return 0, permission("write", f.Name())
+}
+
+// ReadFrom implements io.ReaderFrom
+func (f *readOnlyFile) ReadFrom(r io.Reader) (int64, error) {
+	return 0, permission("write", f.Name())
+}
+
+// Truncate implements File
+func (f *readOnlyFile) Truncate(size int64) error {
+	return permission("truncate", f.Name())
+}
+
+// readOnlySub is the sub file system of a read only file system
+type readOnlySub struct {
+	fsys iofs.FS
+}
+
+func (s *readOnlySub) Open(name string) (iofs.File, error) {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return readOnlyHandle(f), nil
+}
+
+func (s *readOnlySub) ReadDir(name string) ([]iofs.DirEntry, error) {
+	return iofs.ReadDir(s.fsys, name)
+}
+
+func (s *readOnlySub) ReadFile(name string) ([]byte, error) {
+	return iofs.ReadFile(s.fsys, name)
+}
+
+func (s *readOnlySub) Stat(name string) (iofs.FileInfo, error) {
+	return iofs.Stat(s.fsys, name)
+}
+
+func (s *readOnlySub) Glob(pattern string) ([]string, error) {
+	return iofs.Glob(s.fsys, pattern)
+}
+
+func (s *readOnlySub) Sub(dir string) (iofs.FS, error) {
+	sub, err := iofs.Sub(s.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlySub{fsys: sub}, nil
+}
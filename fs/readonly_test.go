@@ -0,0 +1,169 @@
+package fs_test
+
+import (
+	"bytes"
+	"io"
+	iofs "io/fs"
+	stdos "os"
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/fs/fstesting"
+	"github.com/patrickhuber/go-xplat/os"
+	"github.com/patrickhuber/go-xplat/platform"
+	"github.com/stretchr/testify/require"
+)
+
+// setupReadOnly creates a read only wrapper of a memory file system containing /gran/file.txt
+func setupReadOnly(t *testing.T) (fs.FS, fs.FS) {
+	memory, _ := setupMemory(os.NewMock(os.WithPlatform(platform.Linux)))
+	require.NoError(t, memory.MkdirAll("/gran/parent", 0777))
+	require.NoError(t, memory.WriteFile("/gran/file.txt", []byte("content"), 0644))
+	return fs.NewReadOnly(memory), memory
+}
+
+func requirePermission(t *testing.T, err error) {
+	t.Helper()
+	require.ErrorIs(t, err, iofs.ErrPermission)
+	var pathError *iofs.PathError
+	require.ErrorAs(t, err, &pathError)
+}
+
+func TestReadOnlyRejectsChanges(t *testing.T) {
+	fsys, memory := setupReadOnly(t)
+	now := time.Now()
+
+	tests := map[string]func() error{
+		"Create": func() error {
+			_, err := fsys.Create("/gran/new.txt")
+			return err
+		},
+		"WriteFile": func() error {
+			return fsys.WriteFile("/gran/file.txt", []byte("changed"), 0644)
+		},
+		"Rename":    func() error { return fsys.Rename("/gran/file.txt", "/gran/moved.txt") },
+		"Remove":    func() error { return fsys.Remove("/gran/file.txt") },
+		"RemoveAll": func() error { return fsys.RemoveAll("/gran") },
+		"Mkdir":     func() error { return fsys.Mkdir("/gran/child", 0777) },
+		"MkdirAll":  func() error { return fsys.MkdirAll("/gran/child/grand", 0777) },
+		"Symlink":   func() error { return fs.Symlink(fsys, "/gran/file.txt", "/gran/link") },
+		"Link":      func() error { return fs.Link(fsys, "/gran/file.txt", "/gran/link") },
+		"Chmod":     func() error { return fs.Chmod(fsys, "/gran/file.txt", 0600) },
+		"Chtimes":   func() error { return fs.Chtimes(fsys, "/gran/file.txt", now, now) },
+		"Chown":     func() error { return fs.Chown(fsys, "/gran/file.txt", 1, 1) },
+		"Lchown":    func() error { return fs.Lchown(fsys, "/gran/file.txt", 1, 1) },
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			requirePermission(t, test())
+		})
+	}
+
+	flags := []int{
+		stdos.O_WRONLY,
+		stdos.O_RDWR,
+		stdos.O_RDONLY | stdos.O_APPEND,
+		stdos.O_RDONLY | stdos.O_CREATE,
+		stdos.O_RDONLY | stdos.O_TRUNC,
+	}
+	for _, flag := range flags {
+		_, err := fsys.OpenFile("/gran/file.txt", flag, 0644)
+		requirePermission(t, err)
+	}
+
+	content, err := memory.ReadFile("/gran/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+	fstesting.RequireNoOpenHandles(t, memory)
+}
+
+func TestReadOnlyHandleRejectsWrites(t *testing.T) {
+	fsys, memory := setupReadOnly(t)
+
+	f, err := fsys.OpenFile("/gran/file.txt", stdos.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("x"))
+	requirePermission(t, err)
+	_, err = f.WriteAt([]byte("x"), 0)
+	requirePermission(t, err)
+	_, err = f.WriteString("x")
+	requirePermission(t, err)
+	_, err = f.ReadFrom(bytes.NewBufferString("x"))
+	requirePermission(t, err)
+	requirePermission(t, f.Truncate(0))
+
+	// writes can't be reached by asserting the handle returned from Open
+	opened, err := fsys.Open("/gran/file.txt")
+	require.NoError(t, err)
+	defer opened.Close()
+	writer, ok := opened.(io.Writer)
+	require.True(t, ok)
+	_, err = writer.Write([]byte("x"))
+	requirePermission(t, err)
+
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+
+	content, err = memory.ReadFile("/gran/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+}
+
+func TestReadOnlyReads(t *testing.T) {
+	fsys, _ := setupReadOnly(t)
+
+	content, err := fsys.ReadFile("/gran/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+
+	entries, err := fsys.ReadDir("/gran")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	ok, err := fsys.Exists("/gran/parent")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	matches, err := fsys.Glob("/gran/*.txt")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/gran/file.txt"}, matches)
+}
+
+func TestReadOnlySubRejectsWrites(t *testing.T) {
+	fsys, _ := setupReadOnly(t)
+
+	sub, err := fsys.Sub("/gran")
+	require.NoError(t, err)
+
+	content, err := iofs.ReadFile(sub, "file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+
+	f, err := sub.Open("file.txt")
+	require.NoError(t, err)
+	defer f.Close()
+	writer, ok := f.(io.Writer)
+	require.True(t, ok)
+	_, err = writer.Write([]byte("x"))
+	requirePermission(t, err)
+}
+
+func TestReadOnlyForwardsUnsupported(t *testing.T) {
+	plain, _ := setupPlain(t)
+	fsys := fs.NewReadOnly(plain)
+	now := time.Now()
+
+	require.ErrorIs(t, fs.Symlink(fsys, "/gran/file.txt", "/gran/link"), fs.ErrUnsupported)
+	require.ErrorIs(t, fs.Link(fsys, "/gran/file.txt", "/gran/link"), fs.ErrUnsupported)
+	require.ErrorIs(t, fs.Chmod(fsys, "/gran/file.txt", 0600), fs.ErrUnsupported)
+	require.ErrorIs(t, fs.Chtimes(fsys, "/gran/file.txt", now, now), fs.ErrUnsupported)
+	require.ErrorIs(t, fs.Chown(fsys, "/gran/file.txt", 1, 1), fs.ErrUnsupported)
+	require.ErrorIs(t, fs.Lchown(fsys, "/gran/file.txt", 1, 1), fs.ErrUnsupported)
+
+	// other changes are still rejected
+	requirePermission(t, fsys.WriteFile("/gran/file.txt", []byte("changed"), 0644))
+}
@@ -0,0 +1,226 @@
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	iofs "io/fs"
+	"sort"
+	"strings"
+	"testing/fstest"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+)
+
+// SnapshotFS is a file system that can capture the state of all of its entries
+type SnapshotFS interface {
+	// Snapshot returns a copy of every entry. Later changes to the file system do not change the snapshot.
+	Snapshot() *Snapshot
+}
+
+// SnapshotEntry is an entry of a snapshot. Data is the content of a file or the target of a symbolic link.
+type SnapshotEntry struct {
+	Path    string
+	Mode    iofs.FileMode
+	ModTime time.Time
+	Data    []byte
+}
+
+// kind returns a description of the type of the entry
+func (e SnapshotEntry) kind() string {
+	switch {
+	case e.Mode.IsDir():
+		return "directory"
+	case e.Mode&iofs.ModeSymlink != 0:
+		return "symlink"
+	}
+	return "file"
+}
+
+// Snapshot is an immutable copy of the entries of a file system
+type Snapshot struct {
+	processor *filepath.Processor
+	entries   map[string]SnapshotEntry
+}
+
+// Snapshot implements SnapshotFS
+func (m *memory) Snapshot() *Snapshot {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	// hard links share a file so the data is only copied once
+	copies := map[*fstest.MapFile][]byte{}
+	entries := map[string]SnapshotEntry{}
+	for key, file := range m.fs {
+		data, ok := copies[file]
+		if !ok {
+			data = bytes.Clone(file.Data)
+			copies[file] = data
+		}
+		entries[key] = SnapshotEntry{
+			Path:    m.displayPath(key),
+			Mode:    file.Mode,
+			ModTime: file.ModTime,
+			Data:    data,
+		}
+	}
+	return &Snapshot{processor: m.processor, entries: entries}
+}
+
+// displayPath returns the path of the entry with the casing each segment was created with
+func (m *memory) displayPath(key string) string {
+	parent := m.normalizePath(m.processor.Dir(key))
+	if parent == key {
+		return key
+	}
+	return m.processor.Join(m.displayPath(parent), m.displayName(key))
+}
+
+// Paths returns the path of every entry in the snapshot sorted by path
+func (s *Snapshot) Paths() []string {
+	paths := make([]string, 0, len(s.entries))
+	for _, entry := range s.entries {
+		paths = append(paths, entry.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Entry returns the entry at the path. The data is a copy so the snapshot can't be changed through it.
+func (s *Snapshot) Entry(path string) (SnapshotEntry, bool) {
+	entry, ok := s.entries[s.processor.Comparison.Normalize(s.processor.Clean(path))]
+	if !ok {
+		return SnapshotEntry{}, false
+	}
+	entry.Data = bytes.Clone(entry.Data)
+	return entry, true
+}
+
+// Difference is an entry that differs between two snapshots. Before is empty for added entries and After is
+// empty for removed entries.
+type Difference struct {
+	Kind   ChangeKind
+	Path   string
+	Before SnapshotEntry
+	After  SnapshotEntry
+	// Content, Mode and ModTime report what changed in a modified entry
+	Content bool
+	Mode    bool
+	ModTime bool
+}
+
+// String describes the difference on one line
+func (d Difference) String() string {
+	switch d.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("%s %s (%s)", d.Kind, d.Path, describe(d.After))
+	case ChangeRemoved:
+		return fmt.Sprintf("%s %s (%s)", d.Kind, d.Path, describe(d.Before))
+	case ChangeTypeChanged:
+		return fmt.Sprintf("%s %s (%s -> %s)", d.Kind, d.Path, d.Before.kind(), d.After.kind())
+	}
+
+	var details []string
+	if d.Content {
+		details = append(details, fmt.Sprintf("content %s -> %s", describeData(d.Before), describeData(d.After)))
+	}
+	if d.Mode {
+		details = append(details, fmt.Sprintf("mode %s -> %s", d.Before.Mode, d.After.Mode))
+	}
+	if d.ModTime {
+		details = append(details, fmt.Sprintf("mtime %s -> %s",
+			d.Before.ModTime.Format(time.RFC3339Nano), d.After.ModTime.Format(time.RFC3339Nano)))
+	}
+	return fmt.Sprintf("%s %s (%s)", d.Kind, d.Path, strings.Join(details, ", "))
+}
+
+// describe describes the type and size of the entry
+func describe(e SnapshotEntry) string {
+	if e.Mode.IsDir() {
+		return e.kind()
+	}
+	return fmt.Sprintf("%s, %s", e.kind(), describeData(e))
+}
+
+// describeData describes the data of a file or the target of a symbolic link
+func describeData(e SnapshotEntry) string {
+	if e.Mode&iofs.ModeSymlink != 0 {
+		return fmt.Sprintf("%q", e.Data)
+	}
+	return fmt.Sprintf("%d bytes", len(e.Data))
+}
+
+// Differences is the result of Diff sorted by path
+type Differences []Difference
+
+// String describes each difference on its own line
+func (d Differences) String() string {
+	var sb strings.Builder
+	for _, difference := range d {
+		sb.WriteString(difference.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// differ holds the options of Diff
+type differ struct {
+	ignoreModTime bool
+}
+
+type DiffOption = func(*differ)
+
+// WithIgnoreModTime ignores entries whose only change is the modification time. Adding or removing a
+// child changes the modification time of its directory so this keeps diffs to the entries that were written.
+func WithIgnoreModTime() DiffOption {
+	return func(d *differ) {
+		d.ignoreModTime = true
+	}
+}
+
+// Diff returns the entries that were added, removed, modified or changed type between snapshot a and snapshot b
+func Diff(a *Snapshot, b *Snapshot, options ...DiffOption) Differences {
+	d := &differ{}
+	for _, option := range options {
+		option(d)
+	}
+
+	differences := Differences{}
+	for key, before := range a.entries {
+		after, ok := b.entries[key]
+		if !ok {
+			differences = append(differences, Difference{Kind: ChangeRemoved, Path: before.Path, Before: before})
+			continue
+		}
+		if difference, ok := d.compare(before, after); ok {
+			differences = append(differences, difference)
+		}
+	}
+	for key, after := range b.entries {
+		if _, ok := a.entries[key]; !ok {
+			differences = append(differences, Difference{Kind: ChangeAdded, Path: after.Path, After: after})
+		}
+	}
+	sort.Slice(differences, func(i, j int) bool {
+		return differences[i].Path < differences[j].Path
+	})
+	return differences
+}
+
+// compare returns the difference of an entry that is in both snapshots
+func (d *differ) compare(before SnapshotEntry, after SnapshotEntry) (Difference, bool) {
+	difference := Difference{Path: after.Path, Before: before, After: after}
+	if before.Mode.Type() != after.Mode.Type() {
+		difference.Kind = ChangeTypeChanged
+		return difference, true
+	}
+
+	difference.Kind = ChangeModified
+	difference.Content = !bytes.Equal(before.Data, after.Data)
+	difference.Mode = before.Mode != after.Mode
+	difference.ModTime = !d.ignoreModTime && !before.ModTime.Equal(after.ModTime)
+	if !difference.Content && !difference.Mode && !difference.ModTime {
+		return Difference{}, false
+	}
+	return difference, true
+}
@@ -0,0 +1,110 @@
+package fs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/clock"
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/platform"
+	"github.com/stretchr/testify/require"
+)
+
+// setupSnapshot creates a memory file system with a mock clock containing /gran/file.txt and /gran/dir
+func setupSnapshot(t *testing.T, p platform.Platform) (fs.FS, clock.Mock) {
+	c := clock.NewMock()
+	processor := newProcessor(p)
+	fsys := fs.NewMemory(fs.WithProcessor(processor), fs.WithClock(c))
+	require.NoError(t, fsys.MkdirAll("/gran/dir", 0755))
+	require.NoError(t, fsys.WriteFile("/gran/file.txt", []byte("one"), 0644))
+	return fsys, c
+}
+
+func snapshot(t *testing.T, fsys fs.FS) *fs.Snapshot {
+	snapshotFS, ok := fsys.(fs.SnapshotFS)
+	require.True(t, ok)
+	return snapshotFS.Snapshot()
+}
+
+func TestMemorySnapshotIsImmutable(t *testing.T) {
+	fsys, _ := setupSnapshot(t, platform.Linux)
+	before := snapshot(t, fsys)
+
+	require.NoError(t, fsys.WriteFile("/gran/file.txt", []byte("changed"), 0644))
+	require.NoError(t, fsys.Remove("/gran/dir"))
+
+	entry, ok := before.Entry("/gran/file.txt")
+	require.True(t, ok)
+	require.Equal(t, "one", string(entry.Data))
+
+	// changing the returned data does not change the snapshot
+	entry.Data[0] = 'X'
+	entry, ok = before.Entry("/gran/file.txt")
+	require.True(t, ok)
+	require.Equal(t, "one", string(entry.Data))
+
+	require.Equal(t, []string{"/", "/gran", "/gran/dir", "/gran/file.txt"}, before.Paths())
+}
+
+func TestMemorySnapshotPreservesCase(t *testing.T) {
+	fsys, _ := setupSnapshot(t, platform.Darwin)
+	require.NoError(t, fsys.WriteFile("/gran/ReadMe.TXT", []byte("read me"), 0644))
+
+	s := snapshot(t, fsys)
+	require.Contains(t, s.Paths(), "/gran/ReadMe.TXT")
+
+	entry, ok := s.Entry("/GRAN/readme.txt")
+	require.True(t, ok)
+	require.Equal(t, "/gran/ReadMe.TXT", entry.Path)
+}
+
+func TestDiff(t *testing.T) {
+	fsys, c := setupSnapshot(t, platform.Linux)
+	require.NoError(t, fsys.WriteFile("/gran/removed.txt", []byte("removed"), 0644))
+	require.NoError(t, fsys.WriteFile("/gran/mode.txt", []byte("mode"), 0644))
+	before := snapshot(t, fsys)
+
+	c.Advance(time.Hour)
+	require.NoError(t, fsys.WriteFile("/gran/file.txt", []byte("changed"), 0644))
+	require.NoError(t, fsys.WriteFile("/gran/added.txt", []byte("added"), 0644))
+	require.NoError(t, fsys.Remove("/gran/removed.txt"))
+	require.NoError(t, fs.Chmod(fsys, "/gran/mode.txt", 0600))
+	require.NoError(t, fsys.Remove("/gran/dir"))
+	require.NoError(t, fsys.WriteFile("/gran/dir", []byte("now a file"), 0644))
+	after := snapshot(t, fsys)
+
+	differences := fs.Diff(before, after, fs.WithIgnoreModTime())
+	require.Equal(t,
+		"added /gran/added.txt (file, 5 bytes)\n"+
+			"type changed /gran/dir (directory -> file)\n"+
+			"modified /gran/file.txt (content 3 bytes -> 7 bytes)\n"+
+			"modified /gran/mode.txt (mode -rw-r--r-- -> -rw-------)\n"+
+			"removed /gran/removed.txt (file, 7 bytes)\n",
+		differences.String())
+
+	require.Equal(t, fs.ChangeTypeChanged, differences[1].Kind)
+	require.True(t, differences[2].Content)
+	require.False(t, differences[2].Mode)
+	require.True(t, differences[3].Mode)
+}
+
+func TestDiffReportsModTime(t *testing.T) {
+	fsys, c := setupSnapshot(t, platform.Linux)
+	before := snapshot(t, fsys)
+
+	c.Advance(time.Second)
+	now := c.Now()
+	require.NoError(t, fs.Chtimes(fsys, "/gran/file.txt", now, now))
+	after := snapshot(t, fsys)
+
+	differences := fs.Diff(before, after)
+	require.Len(t, differences, 1)
+	require.Equal(t, fs.ChangeModified, differences[0].Kind)
+	require.True(t, differences[0].ModTime)
+	require.Equal(t,
+		"modified /gran/file.txt (mtime 2000-01-01T00:00:00Z -> 2000-01-01T00:00:01Z)",
+		differences[0].String())
+
+	require.Empty(t, fs.Diff(before, after, fs.WithIgnoreModTime()))
+	require.Empty(t, fs.Diff(after, after))
+}
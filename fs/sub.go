@@ -0,0 +1,113 @@
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"strings"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+)
+
+// subFS is an io/fs file system rooted at a directory of the parent file system. Names are
+// validated with io/fs rules and joined to the directory using the processor.
+type subFS struct {
+	fsys      FS
+	processor *filepath.Processor
+	dir       string
+}
+
+// newSubFS creates a file system rooted at dir. The dir is a path of the parent file system.
+func newSubFS(fsys FS, processor *filepath.Processor, dir string) iofs.FS {
+	return &subFS{
+		fsys:      fsys,
+		processor: processor,
+		dir:       dir,
+	}
+}
+
+// fullName returns the name in the parent file system
+func (s *subFS) fullName(op string, name string) (string, error) {
+	if !iofs.ValidPath(name) {
+		return "", &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+	// backslashes and volume names are not valid io/fs names on windows
+	if s.processor.OS.Platform().IsWindows() && strings.ContainsAny(name, `\:`) {
+		return "", &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+	return s.processor.Join(s.dir, name), nil
+}
+
+// fixErr replaces the parent file system name with the sub file system name
+func (s *subFS) fixErr(err error, name string) error {
+	var pathError *iofs.PathError
+	if errors.As(err, &pathError) {
+		pathError.Path = name
+	}
+	return err
+}
+
+func (s *subFS) Open(name string) (iofs.File, error) {
+	full, err := s.fullName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.fsys.Open(full)
+	return f, s.fixErr(err, name)
+}
+
+func (s *subFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	full, err := s.fullName("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.fsys.ReadDir(full)
+	return entries, s.fixErr(err, name)
+}
+
+func (s *subFS) ReadFile(name string) ([]byte, error) {
+	full, err := s.fullName("read", name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.fsys.ReadFile(full)
+	return data, s.fixErr(err, name)
+}
+
+func (s *subFS) Stat(name string) (iofs.FileInfo, error) {
+	full, err := s.fullName("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.fsys.Stat(full)
+	return info, s.fixErr(err, name)
+}
+
+// Glob implements io/fs.GlobFS. Patterns use io/fs syntax, so matching is done with io/fs.Glob
+// against the directory listings instead of the parent's platform specific Glob.
+func (s *subFS) Glob(pattern string) ([]string, error) {
+	return iofs.Glob(readDirFS{s}, pattern)
+}
+
+// readDirFS only exposes Open and ReadDir so io/fs.Glob does not call back into subFS.Glob
+type readDirFS struct {
+	sub *subFS
+}
+
+func (r readDirFS) Open(name string) (iofs.File, error) {
+	return r.sub.Open(name)
+}
+
+func (r readDirFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	return r.sub.ReadDir(name)
+}
+
+func (s *subFS) Sub(dir string) (iofs.FS, error) {
+	if dir == "." {
+		return s, nil
+	}
+	full, err := s.fullName("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	return newSubFS(s.fsys, s.processor, full), nil
+}
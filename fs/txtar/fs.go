@@ -0,0 +1,212 @@
+package txtar
+
+import (
+	iofs "io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+	"github.com/patrickhuber/go-xplat/fs"
+)
+
+// options holds the options of Load and Dump
+type options struct {
+	processor *filepath.Processor
+	modTime   bool
+}
+
+type Option = func(*options)
+
+// WithProcessor sets the processor used to join archive names to the root
+func WithProcessor(processor *filepath.Processor) Option {
+	return func(o *options) {
+		o.processor = processor
+	}
+}
+
+// WithModTime makes Dump annotate every entry with its modification time. Without it golden archives stay
+// the same when the time the file system was created changes.
+func WithModTime() Option {
+	return func(o *options) {
+		o.modTime = true
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, option := range opts {
+		option(o)
+	}
+	if o.processor == nil {
+		o.processor = filepath.NewProcessor()
+	}
+	return o
+}
+
+// LoadString parses the txtar archive in s and loads it into fsys under root
+func LoadString(fsys fs.FS, root string, s string, options ...Option) error {
+	a, err := Parse([]byte(s))
+	if err != nil {
+		return err
+	}
+	return Load(fsys, root, a, options...)
+}
+
+// LoadFile parses the txtar archive name in src and loads it into fsys under root. Use it to seed a memory
+// file system from an archive checked in next to the test.
+func LoadFile(fsys fs.FS, root string, src iofs.FS, name string, options ...Option) error {
+	a, err := ParseFile(src, name)
+	if err != nil {
+		return err
+	}
+	return Load(fsys, root, a, options...)
+}
+
+// Load creates each file of the archive in fsys under root along with any missing parent directories.
+// Modes and modification times are applied after every entry is created so creating a child does not change
+// the modification time of an annotated directory.
+func Load(fsys fs.FS, root string, a *Archive, options ...Option) error {
+	o := newOptions(options)
+
+	names := make([]string, len(a.Files))
+	for i, file := range a.Files {
+		name, err := o.join(root, file.Name)
+		if err != nil {
+			return err
+		}
+		names[i] = name
+
+		if err := fsys.MkdirAll(o.processor.Dir(name), DefaultDirMode); err != nil {
+			return err
+		}
+		switch {
+		case file.Link != "":
+			err = fs.Symlink(fsys, o.processor.FromSlash(file.Link), name)
+		case file.IsDir():
+			err = fsys.MkdirAll(name, DefaultDirMode)
+		default:
+			err = fsys.WriteFile(name, file.Data, DefaultFileMode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, file := range a.Files {
+		if file.Link != "" || !file.HasMode {
+			continue
+		}
+		if err := fs.Chmod(fsys, names[i], file.Mode); err != nil {
+			return err
+		}
+	}
+
+	for i, file := range a.Files {
+		if file.Link != "" || file.ModTime.IsZero() {
+			continue
+		}
+		if err := fs.Chtimes(fsys, names[i], file.ModTime, file.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// join joins the slash separated archive name to root. Names must be clean, relative and stay under root.
+func (o *options) join(root string, name string) (string, error) {
+	trimmed := strings.TrimSuffix(name, "/")
+	if trimmed == "" || path.Clean(trimmed) != trimmed {
+		return "", &iofs.PathError{Op: "load", Path: name, Err: iofs.ErrInvalid}
+	}
+	segments, ok := o.processor.SplitSlash(trimmed, 0)
+	if !ok {
+		return "", &iofs.PathError{Op: "load", Path: name, Err: iofs.ErrInvalid}
+	}
+	return o.processor.Join(append([]string{root}, segments...)...), nil
+}
+
+// DumpString returns the entries of fsys under root as a txtar archive
+func DumpString(fsys fs.FS, root string, options ...Option) (string, error) {
+	a, err := Dump(fsys, root, options...)
+	if err != nil {
+		return "", err
+	}
+	return string(Format(a)), nil
+}
+
+// Dump returns the entries of fsys under root sorted by name. Directories are only listed when they are
+// empty or have something to annotate since files imply their parent directories. Modes are only
+// annotated when they differ from DefaultFileMode or DefaultDirMode. Data is kept as read and Format
+// annotates data without a final newline so loading the formatted archive gives the same files.
+func Dump(fsys fs.FS, root string, options ...Option) (*Archive, error) {
+	o := newOptions(options)
+	a := &Archive{}
+	if err := o.dump(fsys, a, root, ""); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// dump appends the children of the directory at name to the archive. prefix is the archive name of the directory.
+func (o *options) dump(fsys fs.FS, a *Archive, name string, prefix string) error {
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	for _, entry := range entries {
+		child := o.processor.Join(name, entry.Name())
+		info, err := fs.Lstat(fsys, child)
+		if err != nil {
+			return err
+		}
+		file := File{Name: prefix + entry.Name()}
+		mode := info.Mode() & (iofs.ModePerm | iofs.ModeSetuid | iofs.ModeSetgid | iofs.ModeSticky)
+
+		switch {
+		case info.Mode()&iofs.ModeSymlink != 0:
+			target, err := fs.Readlink(fsys, child)
+			if err != nil {
+				return err
+			}
+			file.Link = o.processor.ToSlash(target)
+			a.Files = append(a.Files, file)
+			continue
+		case info.IsDir():
+			file.Name += "/"
+			if mode != DefaultDirMode {
+				file.Mode, file.HasMode = mode, true
+			}
+		default:
+			file.Data, err = fsys.ReadFile(child)
+			if err != nil {
+				return err
+			}
+			if mode != DefaultFileMode {
+				file.Mode, file.HasMode = mode, true
+			}
+		}
+		if o.modTime {
+			file.ModTime = info.ModTime().UTC()
+		}
+
+		if !info.IsDir() {
+			a.Files = append(a.Files, file)
+			continue
+		}
+
+		count := len(a.Files)
+		if err := o.dump(fsys, a, child, file.Name); err != nil {
+			return err
+		}
+		if len(a.Files) == count || file.HasMode || !file.ModTime.IsZero() {
+			// insert the directory before its children
+			a.Files = append(a.Files[:count], append([]File{file}, a.Files[count:]...)...)
+		}
+	}
+	return nil
+}
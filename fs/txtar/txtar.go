@@ -0,0 +1,237 @@
+// Package txtar loads file system fixtures from txtar archives and dumps file systems back to txtar.
+//
+// A txtar archive is a comment followed by files. Each file starts with a marker line holding its name and
+// optional annotations:
+//
+//	-- bin/run.sh mode=0755 mtime=2020-01-02T03:04:05Z --
+//	#!/bin/sh
+//	-- empty/ --
+//	-- latest symlink=bin/run.sh --
+//	-- VERSION newline=false --
+//	1.0.0
+//
+// Names always use forward slashes and are relative to the root the archive is loaded into. A name ending
+// in a slash is a directory and a symlink annotation makes the entry a symbolic link. Every file of a txtar
+// archive ends in a newline, so data without a final newline is annotated with newline=false and the
+// newline is removed again when the archive is parsed.
+package txtar
+
+import (
+	"bytes"
+	"fmt"
+	iofs "io/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultFileMode is the mode of files without a mode annotation
+	DefaultFileMode iofs.FileMode = 0644
+	// DefaultDirMode is the mode of directories without a mode annotation
+	DefaultDirMode iofs.FileMode = 0755
+)
+
+// Archive is a txtar archive
+type Archive struct {
+	Comment []byte
+	Files   []File
+}
+
+// File is a file of a txtar archive
+type File struct {
+	// Name is the slash separated name. Directories end with a slash.
+	Name string
+	Data []byte
+	// Mode is the permission of the entry when HasMode is true
+	Mode iofs.FileMode
+	// HasMode is true if the entry has a mode annotation. Without one DefaultFileMode or DefaultDirMode is used.
+	HasMode bool
+	// ModTime is the modification time of the entry. Zero leaves the time the entry was created.
+	ModTime time.Time
+	// Link is the target of a symbolic link
+	Link string
+}
+
+// IsDir returns true if the file is a directory
+func (f File) IsDir() bool {
+	return strings.HasSuffix(f.Name, "/")
+}
+
+const (
+	markerStart = "-- "
+	markerEnd   = " --"
+)
+
+// Parse parses the txtar archive
+func Parse(data []byte) (*Archive, error) {
+	a := &Archive{}
+	var name string
+	a.Comment, name, data = findMarker(data)
+	for name != "" {
+		file, newline, err := parseMarker(name)
+		if err != nil {
+			return nil, err
+		}
+		file.Data, name, data = findMarker(data)
+		if !newline {
+			file.Data = bytes.TrimSuffix(file.Data, []byte("\n"))
+		}
+		a.Files = append(a.Files, file)
+	}
+	return a, nil
+}
+
+// ParseFile reads and parses the named txtar archive
+func ParseFile(fsys iofs.FS, name string) (*Archive, error) {
+	data, err := iofs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// findMarker returns the data before the next marker line, the content of the marker and the data after it
+func findMarker(data []byte) (before []byte, name string, after []byte) {
+	var i int
+	for {
+		if name, after = isMarker(data[i:]); name != "" {
+			return data[:i], name, after
+		}
+		j := bytes.IndexByte(data[i:], '\n')
+		if j < 0 {
+			return data, "", nil
+		}
+		i += j + 1
+	}
+}
+
+// isMarker returns the content of the marker if data starts with a marker line and the data after the line
+func isMarker(data []byte) (name string, after []byte) {
+	if !bytes.HasPrefix(data, []byte(markerStart)) {
+		return "", nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data, after = data[:i], data[i+1:]
+	}
+	if !bytes.HasSuffix(data, []byte(markerEnd)) || len(data) < len(markerStart)+len(markerEnd) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(data[len(markerStart) : len(data)-len(markerEnd)])), after
+}
+
+// parseMarker splits the content of a marker line into the name and the annotations that follow it. It
+// returns false if the newline that ends the data of the file is not part of it.
+func parseMarker(marker string) (File, bool, error) {
+	file := File{Name: marker}
+	newline := true
+	for {
+		i := strings.LastIndexByte(file.Name, ' ')
+		if i < 0 {
+			break
+		}
+		key, value, ok := strings.Cut(file.Name[i+1:], "=")
+		if !ok {
+			break
+		}
+		switch key {
+		case "mode":
+			mode, err := parseMode(value)
+			if err != nil {
+				return File{}, false, fmt.Errorf("txtar: invalid mode %q of %q: %w", value, marker, err)
+			}
+			file.Mode = mode
+			file.HasMode = true
+		case "mtime":
+			mtime, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return File{}, false, fmt.Errorf("txtar: invalid mtime %q of %q: %w", value, marker, err)
+			}
+			file.ModTime = mtime
+		case "symlink":
+			file.Link = value
+		case "newline":
+			var err error
+			newline, err = strconv.ParseBool(value)
+			if err != nil {
+				return File{}, false, fmt.Errorf("txtar: invalid newline %q of %q: %w", value, marker, err)
+			}
+		default:
+			// not an annotation so it is part of the name
+			return file, newline, nil
+		}
+		file.Name = strings.TrimSpace(file.Name[:i])
+	}
+	return file, newline, nil
+}
+
+// parseMode parses an octal mode including the setuid, setgid and sticky bits
+func parseMode(value string) (iofs.FileMode, error) {
+	bits, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	if bits&^07777 != 0 {
+		return 0, fmt.Errorf("mode out of range")
+	}
+	mode := iofs.FileMode(bits & 0777)
+	if bits&04000 != 0 {
+		mode |= iofs.ModeSetuid
+	}
+	if bits&02000 != 0 {
+		mode |= iofs.ModeSetgid
+	}
+	if bits&01000 != 0 {
+		mode |= iofs.ModeSticky
+	}
+	return mode, nil
+}
+
+// formatMode formats the mode in the octal form read by parseMode
+func formatMode(mode iofs.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&iofs.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&iofs.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&iofs.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+// Format returns the archive in the txtar format. Data without a final newline is annotated with
+// newline=false so Parse returns the same data.
+func Format(a *Archive) []byte {
+	var buf bytes.Buffer
+	buf.Write(fixNewline(a.Comment))
+	for _, file := range a.Files {
+		buf.WriteString(markerStart)
+		buf.WriteString(file.Name)
+		if file.HasMode {
+			buf.WriteString(" mode=" + formatMode(file.Mode))
+		}
+		if !file.ModTime.IsZero() {
+			buf.WriteString(" mtime=" + file.ModTime.UTC().Format(time.RFC3339Nano))
+		}
+		if file.Link != "" {
+			buf.WriteString(" symlink=" + file.Link)
+		}
+		if len(file.Data) > 0 && file.Data[len(file.Data)-1] != '\n' {
+			buf.WriteString(" newline=false")
+		}
+		buf.WriteString(markerEnd + "\n")
+		buf.Write(fixNewline(file.Data))
+	}
+	return buf.Bytes()
+}
+
+// fixNewline adds a final newline to data that does not end in one
+func fixNewline(data []byte) []byte {
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		return append(append([]byte(nil), data...), '\n')
+	}
+	return data
+}
@@ -0,0 +1,219 @@
+package txtar_test
+
+import (
+	iofs "io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/patrickhuber/go-xplat/filepath"
+	"github.com/patrickhuber/go-xplat/fs"
+	"github.com/patrickhuber/go-xplat/fs/txtar"
+	"github.com/patrickhuber/go-xplat/os"
+	"github.com/patrickhuber/go-xplat/platform"
+	"github.com/stretchr/testify/require"
+)
+
+const golden = `golden file system
+-- bin/run.sh mode=0755 --
+#!/bin/sh
+-- empty/ --
+-- etc/config.yml mode=0600 mtime=2020-01-02T03:04:05Z --
+name: config
+-- latest symlink=bin/run.sh --
+-- readme.md --
+# readme
+`
+
+// dumped is golden as written by Dump, which has no comment and leaves out modification times by default
+const dumped = `-- bin/run.sh mode=0755 --
+#!/bin/sh
+-- empty/ --
+-- etc/config.yml mode=0600 --
+name: config
+-- latest symlink=bin/run.sh --
+-- readme.md --
+# readme
+`
+
+func setup(t *testing.T, p platform.Platform) (fs.FS, *filepath.Processor) {
+	processor := filepath.NewProcessorWithOS(os.NewMock(os.WithPlatform(p)))
+	fsys := fs.NewMemory(fs.WithProcessor(processor))
+	return fsys, processor
+}
+
+func TestParse(t *testing.T) {
+	a, err := txtar.Parse([]byte(golden))
+	require.NoError(t, err)
+	require.Equal(t, "golden file system\n", string(a.Comment))
+	require.Len(t, a.Files, 5)
+
+	require.Equal(t, "bin/run.sh", a.Files[0].Name)
+	require.Equal(t, iofs.FileMode(0755), a.Files[0].Mode)
+	require.Equal(t, "#!/bin/sh\n", string(a.Files[0].Data))
+
+	require.True(t, a.Files[1].IsDir())
+
+	require.Equal(t, "etc/config.yml", a.Files[2].Name)
+	require.Equal(t, iofs.FileMode(0600), a.Files[2].Mode)
+	require.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), a.Files[2].ModTime)
+
+	require.Equal(t, "latest", a.Files[3].Name)
+	require.Equal(t, "bin/run.sh", a.Files[3].Link)
+	require.Empty(t, a.Files[3].Data)
+
+	require.Equal(t, golden, string(txtar.Format(a)))
+}
+
+func TestParseKeepsSpacesInName(t *testing.T) {
+	a, err := txtar.Parse([]byte("-- my file.txt mode=04755 --\ndata\n"))
+	require.NoError(t, err)
+	require.Equal(t, "my file.txt", a.Files[0].Name)
+	require.Equal(t, iofs.ModeSetuid|0755, a.Files[0].Mode)
+	require.Equal(t, "-- my file.txt mode=4755 --\ndata\n", string(txtar.Format(a)))
+}
+
+func TestParseInvalidAnnotation(t *testing.T) {
+	_, err := txtar.Parse([]byte("-- file.txt mode=999 --\n"))
+	require.Error(t, err)
+	_, err = txtar.Parse([]byte("-- file.txt mtime=yesterday --\n"))
+	require.Error(t, err)
+	_, err = txtar.Parse([]byte("-- file.txt newline=maybe --\n"))
+	require.Error(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	fsys, _ := setup(t, platform.Linux)
+	require.NoError(t, txtar.LoadString(fsys, "/gran", golden))
+
+	data, err := fsys.ReadFile("/gran/bin/run.sh")
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh\n", string(data))
+
+	info, err := fsys.Stat("/gran/bin/run.sh")
+	require.NoError(t, err)
+	require.Equal(t, iofs.FileMode(0755), info.Mode())
+
+	info, err = fsys.Stat("/gran/etc/config.yml")
+	require.NoError(t, err)
+	require.Equal(t, iofs.FileMode(0600), info.Mode())
+	require.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), info.ModTime().UTC())
+
+	info, err = fsys.Stat("/gran/empty")
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+
+	target, err := fs.Readlink(fsys, "/gran/latest")
+	require.NoError(t, err)
+	require.Equal(t, "bin/run.sh", target)
+}
+
+func TestLoadWindows(t *testing.T) {
+	fsys, processor := setup(t, platform.Windows)
+	require.NoError(t, txtar.LoadString(fsys, `c:\gran`, golden, txtar.WithProcessor(processor)))
+
+	data, err := fsys.ReadFile(`c:\gran\bin\run.sh`)
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh\n", string(data))
+
+	target, err := fs.Readlink(fsys, `c:\gran\latest`)
+	require.NoError(t, err)
+	require.Equal(t, `bin\run.sh`, target)
+
+	s, err := txtar.DumpString(fsys, `c:\gran`, txtar.WithProcessor(processor))
+	require.NoError(t, err)
+	require.Equal(t, dumped, s)
+}
+
+func TestLoadFile(t *testing.T) {
+	src := fstest.MapFS{"testdata/golden.txtar": &fstest.MapFile{Data: []byte(golden)}}
+	fsys, _ := setup(t, platform.Linux)
+	require.NoError(t, txtar.LoadFile(fsys, "/gran", src, "testdata/golden.txtar"))
+	ok, err := fsys.Exists("/gran/readme.md")
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestLoadRejectsEscapingNames(t *testing.T) {
+	for _, name := range []string{"../file.txt", "/file.txt", "a/../../file.txt", "a//b", "./a"} {
+		t.Run(name, func(t *testing.T) {
+			fsys, _ := setup(t, platform.Linux)
+			err := txtar.LoadString(fsys, "/gran", "-- "+name+" --\ndata\n")
+			require.ErrorIs(t, err, iofs.ErrInvalid)
+		})
+	}
+
+	fsys, processor := setup(t, platform.Windows)
+	err := txtar.LoadString(fsys, `c:\gran`, "-- a\\..\\..\\file.txt --\n", txtar.WithProcessor(processor))
+	require.ErrorIs(t, err, iofs.ErrInvalid)
+}
+
+func TestDump(t *testing.T) {
+	fsys, _ := setup(t, platform.Linux)
+	require.NoError(t, txtar.LoadString(fsys, "/gran", golden))
+
+	s, err := txtar.DumpString(fsys, "/gran")
+	require.NoError(t, err)
+	require.Equal(t, dumped, s)
+
+	a, err := txtar.Dump(fsys, "/gran", txtar.WithModTime())
+	require.NoError(t, err)
+	require.Equal(t, "bin/", a.Files[0].Name)
+	require.Equal(t, "etc/", a.Files[3].Name)
+	require.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), a.Files[4].ModTime)
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	fsys, _ := setup(t, platform.Linux)
+	require.NoError(t, txtar.LoadString(fsys, "/gran", golden))
+	a, err := txtar.Dump(fsys, "/gran", txtar.WithModTime())
+	require.NoError(t, err)
+
+	copy, _ := setup(t, platform.Linux)
+	require.NoError(t, txtar.Load(copy, "/other", a))
+	b, err := txtar.Dump(copy, "/other", txtar.WithModTime())
+	require.NoError(t, err)
+	require.Equal(t, string(txtar.Format(a)), string(txtar.Format(b)))
+}
+
+func TestDumpRoundTripNoPermissions(t *testing.T) {
+	const locked = `-- locked/ mode=0000 --
+-- secret.txt mode=0000 --
+secret
+`
+	a, err := txtar.Parse([]byte(locked))
+	require.NoError(t, err)
+	require.True(t, a.Files[1].HasMode)
+	require.Equal(t, iofs.FileMode(0), a.Files[1].Mode)
+
+	fsys, _ := setup(t, platform.Linux)
+	require.NoError(t, txtar.Load(fsys, "/gran", a))
+
+	info, err := fsys.Stat("/gran/secret.txt")
+	require.NoError(t, err)
+	require.Equal(t, iofs.FileMode(0), info.Mode().Perm())
+
+	s, err := txtar.DumpString(fsys, "/gran")
+	require.NoError(t, err)
+	require.Equal(t, locked, s)
+}
+
+func TestDumpRoundTripNoNewline(t *testing.T) {
+	fsys, _ := setup(t, platform.Linux)
+	require.NoError(t, fsys.MkdirAll("/gran", 0755))
+	require.NoError(t, fsys.WriteFile("/gran/VERSION", []byte("1.0.0"), 0644))
+	require.NoError(t, fsys.WriteFile("/gran/lines.txt", []byte("one\ntwo"), 0644))
+
+	s, err := txtar.DumpString(fsys, "/gran")
+	require.NoError(t, err)
+	require.Equal(t, "-- VERSION newline=false --\n1.0.0\n-- lines.txt newline=false --\none\ntwo\n", s)
+
+	copy, _ := setup(t, platform.Linux)
+	require.NoError(t, txtar.LoadString(copy, "/other", s))
+	data, err := copy.ReadFile("/other/VERSION")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0", string(data))
+	data, err = copy.ReadFile("/other/lines.txt")
+	require.NoError(t, err)
+	require.Equal(t, "one\ntwo", string(data))
+}
@@ -5,6 +5,7 @@ go 1.20
 require (
 	github.com/patrickhuber/go-collections v0.0.6
 	github.com/stretchr/testify v1.8.2
+	golang.org/x/text v0.13.0
 )
 
 require (
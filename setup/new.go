@@ -1,6 +1,7 @@
 package setup
 
 import (
+	"github.com/patrickhuber/go-xplat/clock"
 	"github.com/patrickhuber/go-xplat/console"
 	"github.com/patrickhuber/go-xplat/env"
 	"github.com/patrickhuber/go-xplat/filepath"
@@ -16,5 +17,6 @@ func New() *Setup {
 		Path:    filepath.NewProcessorWithOS(os),
 		Env:     env.NewOS(),
 		Console: console.NewOS(),
+		Clock:   clock.New(),
 	}
 }
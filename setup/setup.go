@@ -1,6 +1,7 @@
 package setup
 
 import (
+	"github.com/patrickhuber/go-xplat/clock"
 	"github.com/patrickhuber/go-xplat/console"
 	"github.com/patrickhuber/go-xplat/env"
 	"github.com/patrickhuber/go-xplat/filepath"
@@ -14,4 +15,5 @@ type Setup struct {
 	Path    *filepath.Processor
 	Env     env.Environment
 	Console console.Console
+	Clock   clock.Clock
 }
@@ -2,6 +2,7 @@ package setup
 
 import (
 	"github.com/patrickhuber/go-xplat/arch"
+	"github.com/patrickhuber/go-xplat/clock"
 	"github.com/patrickhuber/go-xplat/console"
 	"github.com/patrickhuber/go-xplat/env"
 	"github.com/patrickhuber/go-xplat/filepath"
@@ -64,11 +65,13 @@ func NewTest(options ...Option) *Setup {
 		os.WithArchitecture(op.arch),
 		os.WithPlatform(op.platform))
 	path := filepath.NewProcessorWithOS(os)
+	clock := clock.NewMock()
 	return &Setup{
 		OS:      os,
-		FS:      fs.NewMemory(fs.WithProcessor(path)),
+		FS:      fs.NewMemory(fs.WithProcessor(path), fs.WithClock(clock)),
 		Path:    path,
 		Env:     env.NewMemoryWithMap(op.vars),
 		Console: console.NewMemory(console.WithArgs(op.args)),
+		Clock:   clock,
 	}
 }